@@ -1,5 +1,5 @@
 import axios from 'axios'
-import { Product, Order, CheckoutResponse, CartItem, ShippingAddress } from './types'
+import { Product, ProductPage, RelatedProduct, Suggestions, Category, Order, CheckoutResponse, CartItem, ShippingAddress } from './types'
 
 // For mirrord DB branching demo: set VITE_INVENTORY_API=http://localhost:18082 so getInventory
 // hits your local branch. Other APIs go through Vite proxy (VITE_PROXY_TARGET=minikube URL).
@@ -8,8 +8,8 @@ const inventoryBase = import.meta.env.VITE_INVENTORY_API ?? ''
 const api = axios.create({ baseURL: '' })
 
 export const getProducts = async (): Promise<Product[]> => {
-  const { data } = await api.get('/api/products')
-  return data
+  const { data } = await api.get<ProductPage>('/api/products')
+  return data.products
 }
 
 export const getProduct = async (id: string): Promise<Product> => {
@@ -17,13 +17,28 @@ export const getProduct = async (id: string): Promise<Product> => {
   return data
 }
 
+export const getRelatedProducts = async (id: string): Promise<RelatedProduct[]> => {
+  const { data } = await api.get<RelatedProduct[]>(`/api/products/${id}/related`)
+  return data
+}
+
 export const searchProducts = async (query: string): Promise<Product[]> => {
-  const { data } = await api.get(`/api/products/search?q=${encodeURIComponent(query)}`)
+  const { data } = await api.get<ProductPage>(`/api/products/search?q=${encodeURIComponent(query)}`)
+  return data.products
+}
+
+export const suggestProducts = async (query: string): Promise<Suggestions> => {
+  const { data } = await api.get<Suggestions>(`/api/products/suggest?q=${encodeURIComponent(query)}`)
   return data
 }
 
 export const getProductsByCategory = async (category: string): Promise<Product[]> => {
-  const { data } = await api.get(`/api/products/category/${category}`)
+  const { data } = await api.get<ProductPage>(`/api/products/category/${category}`)
+  return data.products
+}
+
+export const getCategories = async (): Promise<Category[]> => {
+  const { data } = await api.get<Category[]>('/api/categories')
   return data
 }
 
@@ -45,6 +60,7 @@ export const checkout = async (
     customer_email: customerEmail,
     customer_name: customerName,
     shipping_address: shippingAddress,
+    currency: cart[0]?.price.currency,
     items: cart.map(item => ({
       product_id: item.productId,
       product_name: item.productName,
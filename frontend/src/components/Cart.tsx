@@ -1,5 +1,6 @@
 import { Link } from 'react-router-dom'
 import { CartItem } from '../types'
+import { cartTotal, formatMoney, multiplyMoney } from '../money'
 
 interface CartProps {
   cart: CartItem[]
@@ -7,7 +8,7 @@ interface CartProps {
 }
 
 export default function Cart({ cart, updateQuantity }: CartProps) {
-  const total = cart.reduce((sum, item) => sum + item.price * item.quantity, 0)
+  const total = cartTotal(cart)
 
   if (cart.length === 0) {
     return (
@@ -31,7 +32,7 @@ export default function Cart({ cart, updateQuantity }: CartProps) {
             </div>
             <div className="cart-item-details">
               <h3>{item.productName}</h3>
-              <p className="cart-item-price">${item.price.toFixed(2)}</p>
+              <p className="cart-item-price">{formatMoney(item.price)}</p>
             </div>
             <div className="cart-item-quantity">
               <button onClick={() => updateQuantity(item.productId, item.quantity - 1)}>-</button>
@@ -39,7 +40,7 @@ export default function Cart({ cart, updateQuantity }: CartProps) {
               <button onClick={() => updateQuantity(item.productId, item.quantity + 1)}>+</button>
             </div>
             <div className="cart-item-total">
-              ${(item.price * item.quantity).toFixed(2)}
+              {formatMoney(multiplyMoney(item.price, item.quantity))}
             </div>
             <button
               className="remove-item-btn"
@@ -54,7 +55,7 @@ export default function Cart({ cart, updateQuantity }: CartProps) {
       <div className="cart-summary">
         <div className="cart-total">
           <span>Total:</span>
-          <span>${total.toFixed(2)}</span>
+          <span>{formatMoney(total)}</span>
         </div>
         <Link to="/checkout" className="checkout-btn">
           Proceed to Checkout
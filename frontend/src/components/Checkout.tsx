@@ -3,6 +3,7 @@ import { useNavigate, Link } from 'react-router-dom'
 import { CartItem, ShippingAddress } from '../types'
 import { Button, buttonVariants } from '@metalbear/ui'
 import { checkout } from '../api'
+import { cartTotal, formatMoney, multiplyMoney } from '../money'
 
 interface CheckoutProps {
   cart: CartItem[]
@@ -24,7 +25,7 @@ export default function Checkout({ cart, clearCart }: CheckoutProps) {
     country: 'USA',
   })
 
-  const total = cart.reduce((sum, item) => sum + item.price * item.quantity, 0)
+  const total = cartTotal(cart)
 
   if (cart.length === 0) {
     return (
@@ -172,7 +173,7 @@ export default function Checkout({ cart, clearCart }: CheckoutProps) {
           {error && <div className="checkout-error">{error}</div>}
 
           <Button type="submit" className={buttonVariants({ variant: "brand-primary" })} disabled={loading} style={{ width: '100%' }}>
-            {loading ? 'Processing...' : `Place Order - $${total.toFixed(2)}`}
+            {loading ? 'Processing...' : `Place Order - ${formatMoney(total)}`}
           </Button>
         </form>
 
@@ -181,12 +182,12 @@ export default function Checkout({ cart, clearCart }: CheckoutProps) {
           {cart.map(item => (
             <div key={item.productId} className="summary-item">
               <span>{item.productName} x {item.quantity}</span>
-              <span>${(item.price * item.quantity).toFixed(2)}</span>
+              <span>{formatMoney(multiplyMoney(item.price, item.quantity))}</span>
             </div>
           ))}
           <div className="summary-total">
             <span>Total</span>
-            <span>${total.toFixed(2)}</span>
+            <span>{formatMoney(total)}</span>
           </div>
         </div>
       </div>
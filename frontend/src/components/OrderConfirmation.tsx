@@ -2,6 +2,7 @@ import { useState, useEffect } from 'react'
 import { useParams, useLocation, Link } from 'react-router-dom'
 import { getOrderByToken } from '../api'
 import { Order } from '../types'
+import { formatMoney } from '../money'
 
 export default function OrderConfirmation() {
   const { orderNumber } = useParams<{ orderNumber: string }>()
@@ -30,7 +31,7 @@ export default function OrderConfirmation() {
       <h1>Order Confirmed!</h1>
       <p className="order-number">Order Number: <strong>{orderNumber}</strong></p>
       {totalAmount && (
-        <p className="order-total">Total: <strong>${totalAmount.toFixed(2)}</strong></p>
+        <p className="order-total">Total: <strong>{formatMoney(totalAmount)}</strong></p>
       )}
 
       {order?.source === 'mirrord' && (
@@ -2,6 +2,7 @@ import { useState, useEffect, useRef } from 'react'
 import { useParams, Link } from 'react-router-dom'
 import { Order } from '../types'
 import { getOrderByToken } from '../api'
+import { formatMoney, multiplyMoney } from '../money'
 
 const statusSteps = ['pending', 'processing', 'confirmed', 'shipped', 'delivered']
 
@@ -104,7 +105,7 @@ export default function OrderTracking() {
         </div>
         <div className="detail-row">
           <span>Total:</span>
-          <span>${order.total_amount.toFixed(2)}</span>
+          <span>{formatMoney(order.total_amount)}</span>
         </div>
         <div className="detail-row">
           <span>Ordered:</span>
@@ -124,7 +125,7 @@ export default function OrderTracking() {
           {order.items?.map(item => (
             <div key={item.id} className="order-item">
               <span>{item.product_name} x {item.quantity}</span>
-              <span>${(item.price_at_time * item.quantity).toFixed(2)}</span>
+              <span>{formatMoney(multiplyMoney(item.price_at_time, item.quantity))}</span>
             </div>
           ))}
         </div>
@@ -1,7 +1,8 @@
 import { useState, useEffect } from 'react'
 import { useParams, Link } from 'react-router-dom'
-import { Product, CartItem } from '../types'
-import { getProduct, getInventory } from '../api'
+import { Product, RelatedProduct, CartItem } from '../types'
+import { getProduct, getInventory, getRelatedProducts } from '../api'
+import { formatMoney } from '../money'
 
 interface ProductDetailProps {
   addToCart: (item: CartItem) => void
@@ -10,16 +11,24 @@ interface ProductDetailProps {
 export default function ProductDetail({ addToCart }: ProductDetailProps) {
   const { id } = useParams<{ id: string }>()
   const [product, setProduct] = useState<Product | null>(null)
+  const [related, setRelated] = useState<RelatedProduct[]>([])
   const [stock, setStock] = useState<number>(0)
   const [quantity, setQuantity] = useState(1)
   const [loading, setLoading] = useState(true)
   const [error, setError] = useState<string | null>(null)
   const [added, setAdded] = useState(false)
+  const [imageIndex, setImageIndex] = useState(0)
 
   useEffect(() => {
     if (id) loadProduct(id)
   }, [id])
 
+  useEffect(() => {
+    if (!id) return
+    setRelated([])
+    getRelatedProducts(id).then(setRelated).catch(() => setRelated([]))
+  }, [id])
+
   const loadProduct = async (productId: string) => {
     setLoading(true)
     try {
@@ -28,6 +37,7 @@ export default function ProductDetail({ addToCart }: ProductDetailProps) {
         getInventory(productId).catch(() => ({ stock_quantity: 0, reserved_quantity: 0 }))
       ])
       setProduct(productData)
+      setImageIndex(0)
       setStock(inventoryData.stock_quantity - inventoryData.reserved_quantity)
     } catch (err) {
       setError('Product not found')
@@ -52,23 +62,70 @@ export default function ProductDetail({ addToCart }: ProductDetailProps) {
   if (loading) return <div className="loading">Loading...</div>
   if (error || !product) return <div className="error">{error || 'Product not found'}</div>
 
+  // Thumbnail renditions are for small previews only; the gallery shows the rest.
+  const gallery = (product.media ?? []).filter((m) => m.role !== 'thumbnail')
+  const shown = gallery[imageIndex]
+
   return (
     <div className="product-detail">
       <Link to="/" className="back-link">← Back to Products</Link>
 
       <div className="product-detail-content">
-        <div className="product-detail-image">
-          <img src={product.image_url} alt={product.name} />
+        <div>
+          <div className="product-detail-image">
+            {shown ? (
+              <img src={shown.url} alt={shown.alt_text || product.name} width={shown.width} height={shown.height} />
+            ) : (
+              <img src={product.image_url} alt={product.name} />
+            )}
+          </div>
+          {gallery.length > 1 && (
+            <div className="product-gallery">
+              {gallery.map((m, i) => (
+                <button
+                  key={m.id}
+                  type="button"
+                  className={i === imageIndex ? 'product-gallery-thumb active' : 'product-gallery-thumb'}
+                  onClick={() => setImageIndex(i)}
+                >
+                  <img src={m.url} alt={m.alt_text || product.name} />
+                </button>
+              ))}
+            </div>
+          )}
         </div>
 
         <div className="product-detail-info">
           <span className="product-category-badge">{product.category}</span>
           <h1>{product.name}</h1>
+          {product.review_count > 0 && (
+            <p className="product-rating">
+              {'★'.repeat(Math.round(product.rating))}{'☆'.repeat(5 - Math.round(product.rating))}
+              {' '}{product.rating.toFixed(1)} ({product.review_count} {product.review_count === 1 ? 'review' : 'reviews'})
+            </p>
+          )}
           <p className="product-description">{product.description}</p>
-          <p className="product-price">${product.price.toFixed(2)}</p>
+          {Object.keys(product.attributes ?? {}).length > 0 && (
+            <dl className="product-specs">
+              {Object.entries(product.attributes).map(([name, value]) => (
+                <div key={name}>
+                  <dt>{name.replace(/_/g, ' ')}</dt>
+                  <dd>{typeof value === 'boolean' ? (value ? 'Yes' : 'No') : String(value)}</dd>
+                </div>
+              ))}
+            </dl>
+          )}
+          <p className="product-price">
+            {formatMoney(product.price)}
+            {product.compare_at && (
+              <span className="compare-at-price">{formatMoney(product.compare_at)}</span>
+            )}
+          </p>
 
           <div className="stock-info flex items-center gap-2">
-            {stock > 0 ? (
+            {product.status === 'archived' ? (
+              <span className="out-of-stock">No longer available</span>
+            ) : stock > 0 ? (
               <span className="in-stock">In Stock ({stock} available)</span>
             ) : (
               <span className="out-of-stock">Out of Stock</span>
@@ -83,7 +140,7 @@ export default function ProductDetail({ addToCart }: ProductDetailProps) {
             </button>
           </div>
 
-          {stock > 0 && (
+          {stock > 0 && product.status !== 'archived' && (
             <div className="add-to-cart-section">
               <div className="quantity-selector">
                 <button onClick={() => setQuantity(q => Math.max(1, q - 1))}>-</button>
@@ -100,6 +157,23 @@ export default function ProductDetail({ addToCart }: ProductDetailProps) {
           )}
         </div>
       </div>
+
+      {related.length > 0 && (
+        <div className="related-products">
+          <h2>
+            {related[0].reason === 'bought_together' ? 'Frequently bought together' : 'You may also like'}
+          </h2>
+          <div className="related-products-grid">
+            {related.map(item => (
+              <Link key={item.id} to={`/products/${item.id}`} className="related-product-card">
+                <img src={item.image_url} alt={item.name} />
+                <span className="related-product-name">{item.name}</span>
+                <span className="related-product-price">{formatMoney(item.price)}</span>
+              </Link>
+            ))}
+          </div>
+        </div>
+      )}
     </div>
   )
 }
@@ -1,8 +1,9 @@
 import { useState, useEffect } from 'react'
-import { Link } from 'react-router-dom'
-import { Product, CartItem } from '../types'
-import { getProducts, searchProducts, getProductsByCategory, getInventory } from '../api'
+import { Link, useNavigate } from 'react-router-dom'
+import { Product, CartItem, Category, Suggestions } from '../types'
+import { getProducts, searchProducts, suggestProducts, getProductsByCategory, getCategories, getInventory } from '../api'
 import { Card, CardContent, CardHeader, CardTitle, Button, Badge, SearchInput, Select, SelectContent, SelectItem, SelectTrigger, SelectValue, buttonVariants } from '@metalbear/ui'
+import { formatMoney } from '../money'
 
 interface ProductListProps {
   addToCart: (item: CartItem) => void
@@ -12,17 +13,48 @@ interface ProductWithStock extends Product {
   stock?: number
 }
 
+// Flattens the category tree in display order, indenting subcategories under their parent.
+const flattenCategories = (categories: Category[], depth = 0): { slug: string; label: string }[] =>
+  categories.flatMap(c => [
+    { slug: c.slug, label: `${'\u00a0\u00a0'.repeat(depth)}${c.name}` },
+    ...flattenCategories(c.children, depth + 1),
+  ])
+
 export default function ProductList({ addToCart }: ProductListProps) {
   const [products, setProducts] = useState<ProductWithStock[]>([])
   const [loading, setLoading] = useState(true)
   const [error, setError] = useState<string | null>(null)
   const [search, setSearch] = useState('')
   const [category, setCategory] = useState<string>('all')
+  const [categories, setCategories] = useState<Category[]>([])
+  const [suggestions, setSuggestions] = useState<Suggestions | null>(null)
+  const navigate = useNavigate()
+
+  useEffect(() => {
+    getCategories()
+      .then(setCategories)
+      .catch(err => console.error('Failed to load categories:', err))
+  }, [])
 
   useEffect(() => {
     loadProducts()
   }, [category])
 
+  // Typeahead: ask for completions once typing pauses.
+  useEffect(() => {
+    const query = search.trim()
+    if (!query) {
+      setSuggestions(null)
+      return
+    }
+    const timer = setTimeout(() => {
+      suggestProducts(query)
+        .then(setSuggestions)
+        .catch(() => setSuggestions(null))
+    }, 200)
+    return () => clearTimeout(timer)
+  }, [search])
+
   const loadProducts = async () => {
     setLoading(true)
     setError(null)
@@ -64,6 +96,7 @@ export default function ProductList({ addToCart }: ProductListProps) {
   }
 
   const handleSearch = async () => {
+    setSuggestions(null)
     if (!search.trim()) {
       loadProducts()
       return
@@ -114,14 +147,41 @@ export default function ProductList({ addToCart }: ProductListProps) {
     <div className="pt-20 pb-8 max-w-[1200px] mx-auto product-list-container">
       <div className="search-filters-bar flex gap-4 mb-8 flex-wrap justify-center">
         <div className="flex gap-2 items-center flex-nowrap">
-          <SearchInput
-            placeholder="Search products..."
-            value={search}
-            onChange={(e) => setSearch(e.target.value)}
-            onKeyDown={(e) => e.key === 'Enter' && handleSearch()}
-            onClear={() => setSearch('')}
-            className="w-[600px] max-w-[640px]"
-          />
+          <div className="search-suggest">
+            <SearchInput
+              placeholder="Search products..."
+              value={search}
+              onChange={(e) => setSearch(e.target.value)}
+              onKeyDown={(e) => e.key === 'Enter' && handleSearch()}
+              onClear={() => setSearch('')}
+              className="w-[600px] max-w-[640px]"
+            />
+            {suggestions && (suggestions.products.length > 0 || suggestions.categories.length > 0) && (
+              <ul className="search-suggest-list">
+                {suggestions.categories.map(c => (
+                  <li key={`category-${c.slug}`}>
+                    <button
+                      type="button"
+                      onClick={() => {
+                        setSuggestions(null)
+                        setSearch('')
+                        setCategory(c.slug)
+                      }}
+                    >
+                      <span className="search-suggest-kind">Category</span> {c.name}
+                    </button>
+                  </li>
+                ))}
+                {suggestions.products.map(p => (
+                  <li key={p.id}>
+                    <button type="button" onClick={() => navigate(`/products/${p.id}`)}>
+                      {p.name}
+                    </button>
+                  </li>
+                ))}
+              </ul>
+            )}
+          </div>
           <Button className={buttonVariants({ variant: "brand" })} onClick={handleSearch}>Search</Button>
           <Select value={category} onValueChange={setCategory}>
           <SelectTrigger className="min-w-[200px]">
@@ -129,9 +189,9 @@ export default function ProductList({ addToCart }: ProductListProps) {
           </SelectTrigger>
           <SelectContent>
             <SelectItem value="all">All Categories</SelectItem>
-            <SelectItem value="t-shirts">T-Shirts</SelectItem>
-            <SelectItem value="hoodies">Hoodies</SelectItem>
-            <SelectItem value="accessories">Accessories</SelectItem>
+            {flattenCategories(categories).map(c => (
+              <SelectItem key={c.slug} value={c.slug}>{c.label}</SelectItem>
+            ))}
           </SelectContent>
         </Select>
         </div>
@@ -169,7 +229,10 @@ export default function ProductList({ addToCart }: ProductListProps) {
             <CardContent className="flex flex-col gap-4 flex-1">
               <div className="flex justify-between items-center">
                 <p className="text-xl font-bold m-0">
-                  ${product.price.toFixed(2)}
+                  {formatMoney(product.price)}
+                  {product.compare_at && (
+                    <span className="compare-at-price">{formatMoney(product.compare_at)}</span>
+                  )}
                 </p>
                 {product.stock !== undefined ? (
                   product.stock > 0 ? (
@@ -0,0 +1,16 @@
+import { Money } from './types'
+
+export const formatMoney = (money: Money): string =>
+  new Intl.NumberFormat(undefined, { style: 'currency', currency: money.currency })
+    .format(money.amount / 100)
+
+export const multiplyMoney = (money: Money, quantity: number): Money => ({
+  amount: money.amount * quantity,
+  currency: money.currency,
+})
+
+// Sums the line totals of a cart. Carts hold a single currency; the first item's is used.
+export const cartTotal = (items: { price: Money; quantity: number }[]): Money => ({
+  amount: items.reduce((sum, item) => sum + item.price.amount * item.quantity, 0),
+  currency: items[0]?.price.currency ?? 'USD',
+})
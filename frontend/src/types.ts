@@ -1,17 +1,67 @@
+// Amount in minor units (cents) with its ISO 4217 currency code
+export interface Money {
+  amount: number
+  currency: string
+}
+
 export interface Product {
   id: string
   name: string
   description: string
-  price: number
+  price: Money
+  compare_at?: Money
   image_url: string
   category: string
+  attributes: Record<string, string | number | boolean>
+  status: 'draft' | 'published' | 'archived'
+  publish_at?: string
+  unpublish_at?: string
+  rating: number
+  review_count: number
+  media?: ProductMedia[]
   created_at: string
+  updated_at: string
+}
+
+export interface ProductMedia {
+  id: number
+  url: string
+  alt_text: string
+  width?: number
+  height?: number
+  role?: 'primary' | 'thumbnail' | 'lifestyle'
+  position: number
+}
+
+export interface RelatedProduct extends Product {
+  reason: 'bought_together' | 'same_category'
+  co_purchases: number
+}
+
+export interface Suggestions {
+  query: string
+  products: { id: string; name: string; category?: string; score: number }[]
+  categories: { slug: string; name: string; score: number }[]
+}
+
+export interface Category {
+  slug: string
+  parent_slug?: string
+  name: string
+  sort_order: number
+  children: Category[]
+}
+
+export interface ProductPage {
+  products: Product[]
+  next_cursor?: string
+  total: number
 }
 
 export interface CartItem {
   productId: string
   productName: string
-  price: number
+  price: Money
   quantity: number
   imageUrl: string
 }
@@ -30,7 +80,7 @@ export interface Order {
   customer_email: string
   customer_name: string
   shipping_address: ShippingAddress
-  total_amount: number
+  total_amount: Money
   status: string
   tracking_token: string
   processed_by?: string
@@ -44,9 +94,10 @@ export interface Order {
 export interface OrderItem {
   id: string
   product_id: string
+  variant_id?: string
   product_name: string
   quantity: number
-  price_at_time: number
+  price_at_time: Money
 }
 
 export interface CheckoutResponse {
@@ -54,6 +105,6 @@ export interface CheckoutResponse {
   order_id?: string
   order_number?: string
   tracking_token?: string
-  total_amount?: number
+  total_amount?: Money
   message?: string
 }
@@ -0,0 +1,3 @@
+module github.com/metalbear-co/metalmart/pkg/money
+
+go 1.23
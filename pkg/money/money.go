@@ -0,0 +1,84 @@
+// Package money is the amount type shared by the services that price, sell and
+// record products, so prices travel between them in one representation.
+package money
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is used when a price arrives without a currency.
+const DefaultCurrency = "USD"
+
+// Money is an amount in the minor unit of its currency (cents for USD, EUR and GBP)
+// together with the ISO 4217 currency code. Totals are summed in integer minor units
+// so they never drift by a cent.
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// Mul returns m multiplied by a quantity.
+func (m Money) Mul(quantity int) Money {
+	return Money{Amount: m.Amount * int64(quantity), Currency: m.Currency}
+}
+
+func (m Money) String() string {
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	return fmt.Sprintf("%s%d.%02d %s", sign, amount/100, amount%100, m.Currency)
+}
+
+// ValidCurrency reports whether code looks like an ISO 4217 code (three upper-case letters).
+func ValidCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// NormalizeCurrency upper-cases code and falls back to DefaultCurrency when it is empty.
+func NormalizeCurrency(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return DefaultCurrency
+	}
+	return code
+}
+
+// AcceptedCurrency returns the first currency listed in an Accept-Currency header,
+// upper-cased and without parameters such as ";q=0.9", or "" when none is listed.
+func AcceptedCurrency(header string) string {
+	currency, _, _ := strings.Cut(header, ",")
+	currency, _, _ = strings.Cut(currency, ";")
+	return strings.ToUpper(strings.TrimSpace(currency))
+}
+
+// ParseAmount converts a non-negative decimal string in major units ("19.99") into
+// minor units (1999) without going through floating point.
+func ParseAmount(s string) (int64, error) {
+	whole, frac, _ := strings.Cut(strings.TrimSpace(s), ".")
+	if whole == "" || len(frac) > 2 || strings.HasPrefix(whole, "+") || strings.HasPrefix(whole, "-") {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || units > math.MaxInt64/100-1 {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	frac += strings.Repeat("0", 2-len(frac))
+	cents, err := strconv.ParseInt(frac, 10, 64)
+	if err != nil || frac[0] == '+' || frac[0] == '-' {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	return units*100 + cents, nil
+}
@@ -0,0 +1,7 @@
+module github.com/metalbear-co/metalmart/scripts
+
+go 1.23
+
+require github.com/metalbear-co/metalmart/pkg/money v0.0.0
+
+replace github.com/metalbear-co/metalmart/pkg/money => ../pkg/money
@@ -9,6 +9,8 @@ import (
 	"os"
 	"strconv"
 	"time"
+
+	"github.com/metalbear-co/metalmart/pkg/money"
 )
 
 type OrderRequest struct {
@@ -28,10 +30,10 @@ type Address struct {
 }
 
 type OrderItem struct {
-	ProductID   string  `json:"product_id"`
-	ProductName string  `json:"product_name"`
-	Quantity    int     `json:"quantity"`
-	Price       float64 `json:"price"`
+	ProductID   string      `json:"product_id"`
+	ProductName string      `json:"product_name"`
+	Quantity    int         `json:"quantity"`
+	Price       money.Money `json:"price"`
 }
 
 var products = []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}
@@ -109,7 +111,7 @@ func createOrder(client *http.Client, baseURL string, orderID int) {
 				ProductID:   productID,
 				ProductName: "Test Product",
 				Quantity:    (orderID % 3) + 1,
-				Price:       float64((orderID%50)+10) + 0.99,
+				Price:       money.Money{Amount: int64((orderID%50)+10)*100 + 99, Currency: "USD"},
 			},
 		},
 		ReservationID: fmt.Sprintf("res_load_%d", orderID),
@@ -0,0 +1,108 @@
+// Package backfill records the co-purchases of orders placed before the catalogue
+// consumed order.created events.
+package backfill
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/metalbear-co/metalmart/services/catalogue/models"
+	"github.com/metalbear-co/metalmart/services/catalogue/store"
+)
+
+const (
+	// pageSize bounds the orders fetched from the order service per request.
+	pageSize = 500
+	// retryInterval is how long to wait after the order service or database fails.
+	retryInterval = 30 * time.Second
+)
+
+// Copurchases pages through every order in the order service and records its
+// co-purchases, until done or ctx is cancelled. After an error it retries from the
+// page that failed. Once a run completes it is recorded in the database and later
+// calls return at once. Orders the consumer has already recorded are skipped by the
+// store, so it can run alongside it.
+func Copurchases(ctx context.Context, s *store.PostgresStore, orderURL string) {
+	b := &backfill{store: s, client: &http.Client{Timeout: 30 * time.Second}, orderURL: orderURL}
+	for {
+		err := b.run(ctx)
+		if err == nil {
+			if b.recorded > 0 {
+				log.Printf("Backfilled co-purchases of %d orders", b.recorded)
+			}
+			return
+		}
+		log.Printf("Error backfilling co-purchases, retrying in %s: %v", retryInterval, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+type backfill struct {
+	store    *store.PostgresStore
+	client   *http.Client
+	orderURL string
+	// cursor is the next_cursor of the last page recorded.
+	cursor   string
+	recorded int
+}
+
+func (b *backfill) run(ctx context.Context) error {
+	done, err := b.store.CopurchasesBackfilled()
+	if err != nil || done {
+		return err
+	}
+	for {
+		page, err := b.fetch(ctx)
+		if err != nil {
+			return err
+		}
+		for _, o := range page.Orders {
+			recorded, err := b.store.RecordOrder(o.OrderID, o.ProductIDs)
+			if err != nil {
+				return fmt.Errorf("order %s: %w", o.OrderID, err)
+			}
+			if recorded {
+				b.recorded++
+			}
+		}
+		if page.NextCursor == "" {
+			return b.store.MarkCopurchasesBackfilled()
+		}
+		b.cursor = page.NextCursor
+	}
+}
+
+// fetch returns the page of orders after the cursor.
+func (b *backfill) fetch(ctx context.Context) (*models.OrderProductsPage, error) {
+	q := url.Values{"limit": {fmt.Sprint(pageSize)}}
+	if b.cursor != "" {
+		q.Set("after", b.cursor)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/api/orders/products?%s", b.orderURL, q.Encode()), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("order service returned %s", resp.Status)
+	}
+	var page models.OrderProductsPage
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
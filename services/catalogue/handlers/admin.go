@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/metalbear-co/metalmart/services/catalogue/models"
+)
+
+// AdminListProducts lists products in every lifecycle state. ?status= limits the
+// listing to draft, published or archived products.
+func (h *Handler) AdminListProducts(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseListOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", models.StatusDraft, models.StatusPublished, models.StatusArchived:
+	default:
+		http.Error(w, "status must be draft, published or archived", http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.store.ListAllProducts(status, opts)
+	if err != nil {
+		respondStoreError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, page)
+}
+
+// AdminGetProduct returns a product whatever its lifecycle state.
+func (h *Handler) AdminGetProduct(w http.ResponseWriter, r *http.Request) {
+	product, err := h.store.GetProduct(mux.Vars(r)["id"])
+	if err != nil {
+		respondStoreError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, product)
+}
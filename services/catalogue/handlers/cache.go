@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxCacheEntries = 1000
+
+// responseCache keeps rendered catalogue GET responses in memory. Any write through the
+// API bumps the generation and drops every entry, so readers on this replica never see
+// data older than the last write they could have observed; other replicas catch up
+// within the TTL.
+type responseCache struct {
+	ttl        time.Duration
+	mu         sync.RWMutex
+	generation uint64
+	entries    map[string]*cachedResponse
+}
+
+type cachedResponse struct {
+	body         []byte
+	contentType  string
+	etag         string
+	lastModified string
+	expires      time.Time
+}
+
+func newResponseCache(ttl time.Duration) *responseCache {
+	return &responseCache{ttl: ttl, entries: map[string]*cachedResponse{}}
+}
+
+func (c *responseCache) get(key string) (*cachedResponse, uint64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry := c.entries[key]
+	if entry != nil && time.Now().After(entry.expires) {
+		entry = nil
+	}
+	return entry, c.generation
+}
+
+// put stores entry unless the cache was invalidated since generation was read. The
+// entry expires after the TTL, or at until if that is sooner and not zero.
+func (c *responseCache) put(key string, generation uint64, entry *cachedResponse, until time.Time) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation {
+		return
+	}
+	if len(c.entries) >= maxCacheEntries {
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	entry.expires = time.Now().Add(c.ttl)
+	if !until.IsZero() && until.Before(entry.expires) {
+		entry.expires = until
+	}
+	c.entries[key] = entry
+}
+
+func (c *responseCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	c.entries = map[string]*cachedResponse{}
+}
+
+// Cached serves a GET handler from the response cache and answers conditional requests.
+// Successful responses get a strong ETag over the body and, as Last-Modified, the time
+// the catalogue last changed. That is read before the handler runs, so a change made
+// while the response is built is never hidden behind it, and left out within a second
+// of a change, as a later change in the same second would carry the same date. Entries
+// expire when a publishing window or scheduled price next starts or ends.
+func (h *Handler) Cached(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.RequestURI() + "\x00" + r.Header.Get("Accept-Currency") + "\x00" + r.Header.Get("Accept-Language")
+
+		entry, generation := h.cache.get(key)
+		if entry == nil {
+			var lastModified string
+			changed, nextChange, err := h.store.ChangeTimes()
+			if err != nil {
+				log.Printf("Failed to read catalogue change times: %v", err)
+			} else if !changed.IsZero() && time.Since(changed) >= time.Second {
+				lastModified = changed.UTC().Format(http.TimeFormat)
+			}
+			rec := &responseRecorder{header: http.Header{}, status: http.StatusOK}
+			next(rec, r)
+			if rec.status != http.StatusOK {
+				rec.replay(w)
+				return
+			}
+			sum := sha256.Sum256(rec.body.Bytes())
+			entry = &cachedResponse{
+				body:         rec.body.Bytes(),
+				contentType:  rec.header.Get("Content-Type"),
+				etag:         `"` + hex.EncodeToString(sum[:16]) + `"`,
+				lastModified: lastModified,
+			}
+			h.cache.put(key, generation, entry, nextChange)
+		}
+
+		header := w.Header()
+		header.Set("ETag", entry.etag)
+		header.Set("Cache-Control", "no-cache")
+		header.Add("Vary", "Accept-Currency, Accept-Language")
+		if entry.lastModified != "" {
+			header.Set("Last-Modified", entry.lastModified)
+		}
+		if notModified(r, entry) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		header.Set("Content-Type", entry.contentType)
+		w.Write(entry.body)
+	}
+}
+
+// InvalidateOnWrite drops the response cache after every non-read request.
+func (h *Handler) InvalidateOnWrite(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			h.cache.invalidate()
+		}
+	})
+}
+
+// notModified evaluates If-None-Match, falling back to If-Modified-Since only when no
+// entity tag was sent (RFC 9110 section 13.2.2).
+func notModified(r *http.Request, entry *cachedResponse) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == entry.etag {
+				return true
+			}
+		}
+		return false
+	}
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || entry.lastModified == "" {
+		return false
+	}
+	modified, err := http.ParseTime(entry.lastModified)
+	return err == nil && !modified.After(ims)
+}
+
+// responseRecorder buffers a handler's response so it can be cached.
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) Header() http.Header { return r.header }
+
+func (r *responseRecorder) Write(b []byte) (int, error) { return r.body.Write(b) }
+
+func (r *responseRecorder) WriteHeader(status int) { r.status = status }
+
+func (r *responseRecorder) replay(w http.ResponseWriter) {
+	for k, v := range r.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(r.status)
+	w.Write(r.body.Bytes())
+}
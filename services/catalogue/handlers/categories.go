@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/metalbear-co/metalmart/services/catalogue/models"
+)
+
+func (h *Handler) ListCategories(w http.ResponseWriter, r *http.Request) {
+	tree, err := h.store.ListCategories()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, http.StatusOK, tree)
+}
+
+func (h *Handler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	in, ok := decodeCategoryInput(w, r)
+	if !ok {
+		return
+	}
+
+	category, err := h.store.CreateCategory(in)
+	if err != nil {
+		respondStoreError(w, err)
+		return
+	}
+
+	log.Printf("Category created slug=%s parent=%q", category.Slug, category.ParentSlug)
+	respondJSON(w, http.StatusCreated, category)
+}
+
+func (h *Handler) ReplaceCategory(w http.ResponseWriter, r *http.Request) {
+	slug := mux.Vars(r)["slug"]
+
+	in, ok := decodeCategoryInput(w, r)
+	if !ok {
+		return
+	}
+
+	category, err := h.store.UpdateCategory(slug, in)
+	if err != nil {
+		respondStoreError(w, err)
+		return
+	}
+
+	log.Printf("Category replaced slug=%s new_slug=%s parent=%q", slug, category.Slug, category.ParentSlug)
+	respondJSON(w, http.StatusOK, category)
+}
+
+func (h *Handler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	slug := mux.Vars(r)["slug"]
+
+	if err := h.store.DeleteCategory(slug); err != nil {
+		respondStoreError(w, err)
+		return
+	}
+
+	log.Printf("Category deleted slug=%s", slug)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func decodeCategoryInput(w http.ResponseWriter, r *http.Request) (models.CategoryInput, bool) {
+	var in models.CategoryInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return in, false
+	}
+	in.Normalize()
+	if err := in.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return in, false
+	}
+	return in, true
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/metalbear-co/metalmart/pkg/money"
+	"github.com/metalbear-co/metalmart/services/catalogue/models"
+)
+
+func (h *Handler) ListCurrencyRates(w http.ResponseWriter, r *http.Request) {
+	rates, err := h.store.ListCurrencyRates()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, http.StatusOK, rates)
+}
+
+func (h *Handler) SetCurrencyRate(w http.ResponseWriter, r *http.Request) {
+	var rate models.CurrencyRate
+	if err := json.NewDecoder(r.Body).Decode(&rate); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	rate.Currency = mux.Vars(r)["currency"]
+	rate.Normalize()
+	if err := rate.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	saved, err := h.store.SetCurrencyRate(rate)
+	if err != nil {
+		respondStoreError(w, err)
+		return
+	}
+
+	log.Printf("Currency rate updated currency=%s rate=%s rounding=%s increment=%d", saved.Currency, saved.Rate, saved.Rounding, saved.Increment)
+	respondJSON(w, http.StatusOK, saved)
+}
+
+func (h *Handler) DeleteCurrencyRate(w http.ResponseWriter, r *http.Request) {
+	currency := money.NormalizeCurrency(mux.Vars(r)["currency"])
+
+	if err := h.store.DeleteCurrencyRate(currency); err != nil {
+		respondStoreError(w, err)
+		return
+	}
+
+	log.Printf("Currency rate deleted currency=%s", currency)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) GetPriceList(w http.ResponseWriter, r *http.Request) {
+	prices, err := h.store.GetPriceList(mux.Vars(r)["id"])
+	if err != nil {
+		respondStoreError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, prices)
+}
+
+func (h *Handler) SetListPrice(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	productID, currency := vars["id"], money.NormalizeCurrency(vars["currency"])
+
+	var entry models.PriceListEntry
+	if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !money.ValidCurrency(currency) {
+		http.Error(w, "currency must be a three-letter ISO 4217 code", http.StatusBadRequest)
+		return
+	}
+	if err := entry.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	price := money.Money{Amount: entry.Amount, Currency: currency}
+	if err := h.store.SetListPrice(productID, price); err != nil {
+		respondStoreError(w, err)
+		return
+	}
+
+	log.Printf("List price updated product_id=%s price=%s", productID, price)
+	respondJSON(w, http.StatusOK, price)
+}
+
+func (h *Handler) DeleteListPrice(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	productID, currency := vars["id"], money.NormalizeCurrency(vars["currency"])
+
+	if err := h.store.DeleteListPrice(productID, currency); err != nil {
+		respondStoreError(w, err)
+		return
+	}
+
+	log.Printf("List price deleted product_id=%s currency=%s", productID, currency)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requestCurrency returns the currency prices should be served in: the currency query
+// parameter, else the first Accept-Currency value. "" means the catalogue's own prices.
+func requestCurrency(r *http.Request) (string, error) {
+	currency := r.URL.Query().Get("currency")
+	if currency == "" {
+		currency = r.Header.Get("Accept-Currency")
+	}
+	currency = money.AcceptedCurrency(currency)
+	if currency != "" && !money.ValidCurrency(currency) {
+		return "", errors.New("currency must be a three-letter ISO 4217 code")
+	}
+	return currency, nil
+}
+
+// localize converts the prices of products into currency; it does nothing when no
+// currency was requested.
+func (h *Handler) localize(currency string, products ...*models.Product) error {
+	if currency == "" {
+		return nil
+	}
+	return h.store.Localize(currency, products...)
+}
+
+func productRefs(products []models.Product) []*models.Product {
+	refs := make([]*models.Product, len(products))
+	for i := range products {
+		refs[i] = &products[i]
+	}
+	return refs
+}
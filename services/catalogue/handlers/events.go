@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"log"
+	"time"
+
+	"github.com/metalbear-co/metalmart/services/catalogue/models"
+)
+
+// publishProduct announces a change to product id. Created and updated events carry
+// the product as stored, variants included. Publishing is best effort: a failure is
+// logged and never fails the request that made the change.
+func (h *Handler) publishProduct(eventType, id string) {
+	if h.producer == nil {
+		return
+	}
+
+	event := models.ProductEvent{Type: eventType, ProductID: id, OccurredAt: time.Now().UTC()}
+	if eventType != models.EventProductDeleted {
+		product, err := h.store.GetProduct(id)
+		if err != nil {
+			log.Printf("Warning: Failed to load product %s for %s event: %v", id, eventType, err)
+			return
+		}
+		event.Product = product
+	}
+
+	if err := h.producer.PublishProductEvent(event); err != nil {
+		log.Printf("Warning: Failed to publish %s event for product %s: %v", eventType, id, err)
+		return
+	}
+	log.Printf("Published to Kafka: %s product_id=%s", eventType, id)
+}
@@ -2,72 +2,377 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/metalbear-co/metalmart/pkg/money"
+	"github.com/metalbear-co/metalmart/services/catalogue/kafka"
+	"github.com/metalbear-co/metalmart/services/catalogue/models"
 	"github.com/metalbear-co/metalmart/services/catalogue/store"
 )
 
 type Handler struct {
-	store *store.PostgresStore
+	store      *store.PostgresStore
+	cache      *responseCache
+	producer   *kafka.Producer
+	orderURL   string
+	httpClient *http.Client
 }
 
-func NewHandler(s *store.PostgresStore) *Handler {
-	return &Handler{store: s}
+// NewHandler returns a Handler whose cached reads live for cacheTTL; zero disables
+// the response cache but keeps ETag and conditional GET support. p may be nil, in
+// which case no product events are published. orderURL is the order service used to
+// verify review purchases; "" marks every review unverified.
+func NewHandler(s *store.PostgresStore, cacheTTL time.Duration, p *kafka.Producer, orderURL string) *Handler {
+	return &Handler{
+		store:      s,
+		cache:      newResponseCache(cacheTTL),
+		producer:   p,
+		orderURL:   orderURL,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
 }
 
 func (h *Handler) ListProducts(w http.ResponseWriter, r *http.Request) {
-	products, err := h.store.ListProducts()
+	opts, err := parseListOptions(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	currency, err := requestCurrency(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	locales, err := requestLocales(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.store.ListProducts(opts)
+	if err != nil {
+		respondStoreError(w, err)
+		return
+	}
+	refs := productRefs(page.Products)
+	if err := h.localize(currency, refs...); err != nil {
+		respondStoreError(w, err)
+		return
+	}
+	if err := h.translate(locales, refs...); err != nil {
+		respondStoreError(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(products)
+	json.NewEncoder(w).Encode(page)
 }
 
 func (h *Handler) GetProduct(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	product, err := h.store.GetProduct(id)
+	currency, err := requestCurrency(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	locales, err := requestLocales(r)
 	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	product, err := h.store.GetProduct(id)
+	if err != nil || !product.Resolvable(time.Now()) {
 		http.Error(w, "Product not found", http.StatusNotFound)
 		return
 	}
+	if err := h.localize(currency, product); err != nil {
+		respondStoreError(w, err)
+		return
+	}
+	if err := h.translate(locales, product); err != nil {
+		respondStoreError(w, err)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(product)
 }
 
 func (h *Handler) SearchProducts(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query().Get("q")
-	if query == "" {
-		h.ListProducts(w, r)
+	filters, err := parseSearchFilters(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	products, err := h.store.SearchProducts(query)
+	opts, err := parseListOptions(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	currency, err := requestCurrency(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	locales, err := requestLocales(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filters.Locales = locales
+
+	page, err := h.store.SearchProducts(filters, opts)
+	if err != nil {
+		respondStoreError(w, err)
+		return
+	}
+	hits := make([]*models.Product, len(page.Products))
+	for i := range page.Products {
+		hits[i] = &page.Products[i].Product
+	}
+	if err := h.localize(currency, hits...); err != nil {
+		respondStoreError(w, err)
+		return
+	}
+	if err := h.translate(locales, hits...); err != nil {
+		respondStoreError(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(products)
+	json.NewEncoder(w).Encode(page)
 }
 
 func (h *Handler) ListByCategory(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	category := vars["category"]
 
-	products, err := h.store.ListByCategory(category)
+	opts, err := parseListOptions(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	currency, err := requestCurrency(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	locales, err := requestLocales(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.store.ListByCategory(category, opts)
+	if err != nil {
+		respondStoreError(w, err)
+		return
+	}
+	refs := productRefs(page.Products)
+	if err := h.localize(currency, refs...); err != nil {
+		respondStoreError(w, err)
+		return
+	}
+	if err := h.translate(locales, refs...); err != nil {
+		respondStoreError(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(products)
+	json.NewEncoder(w).Encode(page)
+}
+
+func (h *Handler) CreateProduct(w http.ResponseWriter, r *http.Request) {
+	var in models.ProductInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	in.Normalize()
+	if err := in.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	product, err := h.store.CreateProduct(in)
+	if err != nil {
+		respondStoreError(w, err)
+		return
+	}
+
+	log.Printf("Product created id=%s name=%q", product.ID, product.Name)
+	h.publishProduct(models.EventProductCreated, product.ID)
+	respondJSON(w, http.StatusCreated, product)
+}
+
+func (h *Handler) ReplaceProduct(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var in models.ProductInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	in.Normalize()
+	if in.ID != "" && in.ID != id {
+		http.Error(w, "id in body does not match id in path", http.StatusBadRequest)
+		return
+	}
+	if err := in.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	product, err := h.store.UpdateProduct(id, in)
+	if err != nil {
+		respondStoreError(w, err)
+		return
+	}
+
+	log.Printf("Product replaced id=%s", product.ID)
+	h.publishProduct(models.EventProductUpdated, product.ID)
+	respondJSON(w, http.StatusOK, product)
+}
+
+func (h *Handler) PatchProduct(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var patch models.ProductPatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	existing, err := h.store.GetProduct(id)
+	if err != nil {
+		respondStoreError(w, err)
+		return
+	}
+
+	in := patch.Apply(*existing)
+	in.Normalize()
+	if err := in.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	product, err := h.store.UpdateProduct(id, in)
+	if err != nil {
+		respondStoreError(w, err)
+		return
+	}
+
+	log.Printf("Product patched id=%s", product.ID)
+	h.publishProduct(models.EventProductUpdated, product.ID)
+	respondJSON(w, http.StatusOK, product)
+}
+
+func (h *Handler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if err := h.store.DeleteProduct(id); err != nil {
+		respondStoreError(w, err)
+		return
+	}
+
+	log.Printf("Product deleted id=%s", id)
+	h.publishProduct(models.EventProductDeleted, id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseListOptions reads the limit, cursor and sort query parameters.
+func parseListOptions(r *http.Request) (models.ListOptions, error) {
+	q := r.URL.Query()
+	opts := models.ListOptions{
+		Cursor: q.Get("cursor"),
+		Sort:   q.Get("sort"),
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > models.MaxPageSize {
+			return opts, fmt.Errorf("limit must be between 1 and %d", models.MaxPageSize)
+		}
+		opts.Limit = limit
+	}
+	return opts, nil
+}
+
+// parseSearchFilters reads q, category (repeated or comma-separated), min_price and
+// max_price (decimal, e.g. 19.99) and attr.<name>=<value> (repeated for alternatives).
+// Price filters and facets are in the catalogue's base currency.
+func parseSearchFilters(r *http.Request) (models.SearchFilters, error) {
+	q := r.URL.Query()
+	f := models.SearchFilters{
+		Query:           strings.TrimSpace(q.Get("q")),
+		Attributes:      map[string][]string{},
+		AttributeRanges: map[string]models.AttributeRange{},
+	}
+	for _, v := range q["category"] {
+		for _, c := range strings.Split(v, ",") {
+			if c = strings.TrimSpace(c); c != "" {
+				f.Categories = append(f.Categories, c)
+			}
+		}
+	}
+	for name, dst := range map[string]**int64{"min_price": &f.MinPrice, "max_price": &f.MaxPrice} {
+		if v := q.Get(name); v != "" {
+			price, err := money.ParseAmount(v)
+			if err != nil {
+				return f, fmt.Errorf("%s must be a non-negative decimal amount such as 19.99", name)
+			}
+			*dst = &price
+		}
+	}
+	for key, values := range q {
+		name, ok := strings.CutPrefix(key, "attr.")
+		if !ok || name == "" {
+			continue
+		}
+		for _, v := range values {
+			r, isRange := models.ParseAttributeRange(v)
+			if !isRange {
+				f.Attributes[name] = append(f.Attributes[name], v)
+				continue
+			}
+			if _, dup := f.AttributeRanges[name]; dup {
+				return f, fmt.Errorf("attr.%s accepts at most one range", name)
+			}
+			f.AttributeRanges[name] = r
+		}
+	}
+	return f, f.Validate()
+}
+
+func respondJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// respondStoreError maps store sentinel errors to 4xx responses and everything else to 500.
+func respondStoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound), errors.Is(err, store.ErrCategoryNotFound), errors.Is(err, store.ErrPriceNotFound),
+		errors.Is(err, store.ErrReviewNotFound), errors.Is(err, store.ErrTranslationNotFound),
+		errors.Is(err, store.ErrMediaNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, store.ErrDuplicateID), errors.Is(err, store.ErrDuplicateSKU), errors.Is(err, store.ErrCategoryInUse),
+		errors.Is(err, store.ErrPriceEnded), errors.Is(err, store.ErrDuplicateReview):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, models.ErrValidation):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
 }
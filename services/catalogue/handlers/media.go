@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/metalbear-co/metalmart/services/catalogue/models"
+)
+
+// ListMedia returns the image gallery of a storefront product in display order.
+func (h *Handler) ListMedia(w http.ResponseWriter, r *http.Request) {
+	product, err := h.store.GetProduct(mux.Vars(r)["id"])
+	if err != nil || !product.Resolvable(time.Now()) {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+	respondJSON(w, http.StatusOK, product.Media)
+}
+
+// AddMedia attaches an image to a product. With role primary it also becomes the
+// product's image_url.
+func (h *Handler) AddMedia(w http.ResponseWriter, r *http.Request) {
+	productID := mux.Vars(r)["id"]
+
+	var in models.MediaInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	in.Normalize()
+	if err := in.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	media, err := h.store.AddMedia(productID, in)
+	if err != nil {
+		respondStoreError(w, err)
+		return
+	}
+
+	log.Printf("Media added product_id=%s media_id=%d role=%s", productID, media.ID, media.Role)
+	h.publishProduct(models.EventProductUpdated, productID)
+	respondJSON(w, http.StatusCreated, media)
+}
+
+func (h *Handler) ReplaceMedia(w http.ResponseWriter, r *http.Request) {
+	productID, mediaID, ok := mediaPath(w, r)
+	if !ok {
+		return
+	}
+
+	var in models.MediaInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	in.Normalize()
+	if err := in.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	media, err := h.store.UpdateMedia(productID, mediaID, in)
+	if err != nil {
+		respondStoreError(w, err)
+		return
+	}
+
+	log.Printf("Media replaced product_id=%s media_id=%d role=%s", productID, mediaID, media.Role)
+	h.publishProduct(models.EventProductUpdated, productID)
+	respondJSON(w, http.StatusOK, media)
+}
+
+func (h *Handler) DeleteMedia(w http.ResponseWriter, r *http.Request) {
+	productID, mediaID, ok := mediaPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store.DeleteMedia(productID, mediaID); err != nil {
+		respondStoreError(w, err)
+		return
+	}
+
+	log.Printf("Media deleted product_id=%s media_id=%d", productID, mediaID)
+	h.publishProduct(models.EventProductUpdated, productID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReorderMedia sets the gallery order and returns the reordered gallery.
+func (h *Handler) ReorderMedia(w http.ResponseWriter, r *http.Request) {
+	productID := mux.Vars(r)["id"]
+
+	var order models.MediaOrder
+	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := order.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	media, err := h.store.ReorderMedia(productID, order.MediaIDs)
+	if err != nil {
+		respondStoreError(w, err)
+		return
+	}
+
+	log.Printf("Media reordered product_id=%s order=%v", productID, order.MediaIDs)
+	h.publishProduct(models.EventProductUpdated, productID)
+	respondJSON(w, http.StatusOK, media)
+}
+
+// mediaPath reads the product and media ids from the path, answering 404 for a
+// malformed media id.
+func mediaPath(w http.ResponseWriter, r *http.Request) (string, int64, bool) {
+	vars := mux.Vars(r)
+	mediaID, err := strconv.ParseInt(vars["mediaId"], 10, 64)
+	if err != nil {
+		http.Error(w, "media not found", http.StatusNotFound)
+		return "", 0, false
+	}
+	return vars["id"], mediaID, true
+}
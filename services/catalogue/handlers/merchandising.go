@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/metalbear-co/metalmart/services/catalogue/models"
+)
+
+func (h *Handler) GetMerchandising(w http.ResponseWriter, r *http.Request) {
+	m, err := h.store.GetMerchandising()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, http.StatusOK, m)
+}
+
+func (h *Handler) ReorderProducts(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeRankingRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store.ReorderProducts(req.ProductIDs); err != nil {
+		respondStoreError(w, err)
+		return
+	}
+
+	log.Printf("Display order updated: %v", req.ProductIDs)
+	h.GetMerchandising(w, r)
+}
+
+func (h *Handler) SetFeatured(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeRankingRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store.SetFeatured(req.ProductIDs); err != nil {
+		respondStoreError(w, err)
+		return
+	}
+
+	log.Printf("Featured products updated: %v", req.ProductIDs)
+	h.GetMerchandising(w, r)
+}
+
+func (h *Handler) RankCategory(w http.ResponseWriter, r *http.Request) {
+	category := mux.Vars(r)["category"]
+
+	req, ok := decodeRankingRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store.RankCategory(category, req.ProductIDs); err != nil {
+		respondStoreError(w, err)
+		return
+	}
+
+	log.Printf("Category ranking updated category=%s: %v", category, req.ProductIDs)
+	h.GetMerchandising(w, r)
+}
+
+func decodeRankingRequest(w http.ResponseWriter, r *http.Request) (models.RankingRequest, bool) {
+	var req models.RankingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return req, false
+	}
+	if err := req.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/metalbear-co/metalmart/services/catalogue/models"
+)
+
+func (h *Handler) GetPriceHistory(w http.ResponseWriter, r *http.Request) {
+	history, err := h.store.PriceHistory(mux.Vars(r)["id"])
+	if err != nil {
+		respondStoreError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, history)
+}
+
+// SchedulePrice sets a price that overrides the product's list price from
+// effective_from until effective_to, after which the list price applies again.
+func (h *Handler) SchedulePrice(w http.ResponseWriter, r *http.Request) {
+	productID := mux.Vars(r)["id"]
+
+	var in models.ScheduledPriceInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	product, err := h.store.GetProduct(productID)
+	if err != nil {
+		respondStoreError(w, err)
+		return
+	}
+	now := time.Now()
+	in.Normalize(product.ListPrice.Currency, now)
+	if err := in.Validate(product.ListPrice.Currency, now); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	change, err := h.store.SchedulePrice(productID, in)
+	if err != nil {
+		respondStoreError(w, err)
+		return
+	}
+
+	log.Printf("Price scheduled product_id=%s price_id=%d price=%s from=%s", productID, change.ID, change.Price, change.EffectiveFrom.Format(time.RFC3339))
+	h.publishProduct(models.EventProductUpdated, productID)
+	respondJSON(w, http.StatusCreated, change)
+}
+
+// CancelScheduledPrice deletes a scheduled price that has not started and ends one that
+// is in effect now.
+func (h *Handler) CancelScheduledPrice(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	productID := vars["id"]
+	priceID, err := strconv.ParseInt(vars["priceId"], 10, 64)
+	if err != nil {
+		http.Error(w, "scheduled price not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.store.CancelScheduledPrice(productID, priceID); err != nil {
+		respondStoreError(w, err)
+		return
+	}
+
+	log.Printf("Scheduled price cancelled product_id=%s price_id=%d", productID, priceID)
+	h.publishProduct(models.EventProductUpdated, productID)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/metalbear-co/metalmart/services/catalogue/models"
+)
+
+// RelatedProducts returns cross-sell recommendations for a product: products often
+// bought together with it, then similar products from its category.
+func (h *Handler) RelatedProducts(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	limit := models.DefaultRelatedLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > models.MaxRelatedLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", models.MaxRelatedLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	currency, err := requestCurrency(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	locales, err := requestLocales(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	product, err := h.store.GetProduct(id)
+	if err != nil || !product.Resolvable(time.Now()) {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+
+	related, err := h.store.RelatedProducts(id, limit)
+	if err != nil {
+		respondStoreError(w, err)
+		return
+	}
+	refs := make([]*models.Product, len(related))
+	for i := range related {
+		refs[i] = &related[i].Product
+	}
+	if err := h.localize(currency, refs...); err != nil {
+		respondStoreError(w, err)
+		return
+	}
+	if err := h.translate(locales, refs...); err != nil {
+		respondStoreError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, related)
+}
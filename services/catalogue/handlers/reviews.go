@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/metalbear-co/metalmart/services/catalogue/models"
+)
+
+// ListReviews pages through the approved reviews of a product, newest first.
+func (h *Handler) ListReviews(w http.ResponseWriter, r *http.Request) {
+	productID := mux.Vars(r)["id"]
+	opts, err := parseListOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	product, err := h.store.GetProduct(productID)
+	if err != nil || !product.Resolvable(time.Now()) {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+
+	page, err := h.store.ListReviews(productID, models.ReviewApproved, opts)
+	if err != nil {
+		respondStoreError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, page)
+}
+
+// CreateReview accepts a customer review for moderation. It is marked as a verified
+// purchase when the order service has a shipped order from the author with the product.
+func (h *Handler) CreateReview(w http.ResponseWriter, r *http.Request) {
+	productID := mux.Vars(r)["id"]
+
+	var in models.ReviewInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	in.Normalize()
+	if err := in.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	product, err := h.store.GetProduct(productID)
+	if err != nil || !product.Resolvable(time.Now()) {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+
+	verified, err := h.hasShippedOrder(in.AuthorEmail, productID)
+	if err != nil {
+		// The review is still accepted; moderators see it as unverified.
+		log.Printf("Warning: Failed to verify purchase for review of product %s: %v", productID, err)
+	}
+
+	review, err := h.store.CreateReview(productID, in, verified)
+	if err != nil {
+		respondStoreError(w, err)
+		return
+	}
+
+	log.Printf("Review created id=%s product_id=%s rating=%d verified=%t", review.ID, productID, review.Rating, verified)
+	respondJSON(w, http.StatusCreated, review)
+}
+
+// AdminListReviews is the moderation queue: reviews of every product, optionally
+// filtered by status and product_id.
+func (h *Handler) AdminListReviews(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseListOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q := r.URL.Query()
+
+	status := q.Get("status")
+	switch status {
+	case "", models.ReviewPending, models.ReviewApproved, models.ReviewRejected:
+	default:
+		http.Error(w, "status must be pending, approved or rejected", http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.store.ListReviews(q.Get("product_id"), status, opts)
+	if err != nil {
+		respondStoreError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, page)
+}
+
+func (h *Handler) ModerateReview(w http.ResponseWriter, r *http.Request) {
+	reviewID := mux.Vars(r)["reviewId"]
+
+	var m models.ReviewModeration
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := m.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	review, err := h.store.ModerateReview(reviewID, m.Status)
+	if err != nil {
+		respondStoreError(w, err)
+		return
+	}
+
+	log.Printf("Review moderated id=%s product_id=%s status=%s", review.ID, review.ProductID, review.Status)
+	h.publishProduct(models.EventProductUpdated, review.ProductID)
+	respondJSON(w, http.StatusOK, review)
+}
+
+// hasShippedOrder asks the order service whether email has a shipped order
+// containing productID.
+func (h *Handler) hasShippedOrder(email, productID string) (bool, error) {
+	if h.orderURL == "" {
+		return false, nil
+	}
+	q := url.Values{"email": {email}, "status": {"shipped"}, "product_id": {productID}}
+	resp, err := h.httpClient.Get(fmt.Sprintf("%s/api/orders?%s", h.orderURL, q.Encode()))
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("order service returned %s", resp.Status)
+	}
+	var orders []struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&orders); err != nil {
+		return false, err
+	}
+	return len(orders) > 0, nil
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/metalbear-co/metalmart/services/catalogue/models"
+)
+
+// maxSuggestQuery bounds the text matched per keystroke.
+const maxSuggestQuery = 100
+
+// Suggest serves typeahead completions for the search box: product names and
+// categories only, without prices or descriptions.
+func (h *Handler) Suggest(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+	if len(q) > maxSuggestQuery {
+		http.Error(w, fmt.Sprintf("q must be at most %d characters", maxSuggestQuery), http.StatusBadRequest)
+		return
+	}
+	limit := models.DefaultSuggestLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > models.MaxSuggestLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", models.MaxSuggestLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	locales, err := requestLocales(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	suggestions, err := h.store.Suggest(q, limit, locales)
+	if err != nil {
+		respondStoreError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, suggestions)
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/metalbear-co/metalmart/services/catalogue/models"
+)
+
+const (
+	maxImportBytes    = 10 << 20
+	exportFlushEvery  = 100
+	maxNDJSONLineSize = 1 << 20
+)
+
+// importRow is a parsed input row and its line number in the uploaded file.
+type importRow struct {
+	line int
+	in   models.ProductInput
+	err  error
+}
+
+// ImportProducts upserts products from a CSV or NDJSON upload. Every row is validated
+// first; if any row fails, the response is 422 with the row errors and nothing is
+// written. With dry_run=true the import is validated and run in a rolled back
+// transaction so the counts show what would change.
+func (h *Handler) ImportProducts(w http.ResponseWriter, r *http.Request) {
+	format := importFormat(r)
+	if format == "" {
+		http.Error(w, "Content-Type must be text/csv or application/x-ndjson (or pass format=csv|ndjson)", http.StatusUnsupportedMediaType)
+		return
+	}
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+
+	body := http.MaxBytesReader(w, r.Body, maxImportBytes)
+	var rows []importRow
+	var err error
+	if format == models.FormatCSV {
+		rows, err = readCSVRows(body)
+	} else {
+		rows, err = readNDJSONRows(body)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result := models.ImportResult{DryRun: dryRun, Rows: len(rows), Errors: []models.ImportError{}}
+	inputs := make([]models.ProductInput, 0, len(rows))
+	seen := make(map[string]int, len(rows))
+	for _, row := range rows {
+		in := row.in
+		err := row.err
+		if err == nil {
+			in.Normalize()
+			err = in.Validate()
+		}
+		if err == nil && in.ID != "" {
+			if first, ok := seen[in.ID]; ok {
+				err = fmt.Errorf("duplicate id, first seen on row %d", first)
+			}
+			seen[in.ID] = row.line
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, models.ImportError{Row: row.line, ID: in.ID, Error: err.Error()})
+			continue
+		}
+		inputs = append(inputs, in)
+	}
+	if len(result.Errors) > 0 {
+		respondJSON(w, http.StatusUnprocessableEntity, result)
+		return
+	}
+
+	created, updated, err := h.store.ImportProducts(inputs, dryRun)
+	if err != nil {
+		respondStoreError(w, err)
+		return
+	}
+	result.Created, result.Updated = len(created), len(updated)
+	if !dryRun {
+		for _, id := range created {
+			h.publishProduct(models.EventProductCreated, id)
+		}
+		for _, id := range updated {
+			h.publishProduct(models.EventProductUpdated, id)
+		}
+	}
+
+	log.Printf("Products imported format=%s dry_run=%t rows=%d created=%d updated=%d", format, dryRun, result.Rows, result.Created, result.Updated)
+	respondJSON(w, http.StatusOK, result)
+}
+
+// ExportProducts streams every product as CSV (the default) or NDJSON. Both formats
+// can be fed back into ImportProducts.
+func (h *Handler) ExportProducts(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = models.FormatCSV
+	}
+	flusher, _ := w.(http.Flusher)
+	flush := func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	var write func(p models.Product) error
+	var finish func() error
+	switch format {
+	case models.FormatCSV:
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		cw := csv.NewWriter(w)
+		if err := cw.Write(models.CSVColumns); err != nil {
+			return
+		}
+		write = func(p models.Product) error { return cw.Write(p.CSVRecord()) }
+		finish = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	case models.FormatNDJSON:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		write = func(p models.Product) error { return enc.Encode(p) }
+		finish = func() error { return nil }
+	default:
+		http.Error(w, "format must be csv or ndjson", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="products.%s"`, format))
+
+	n := 0
+	err := h.store.EachProduct(func(p models.Product) error {
+		// Export the product as stored; scheduled prices are not part of it.
+		p.Price, p.CompareAt = p.ListPrice, nil
+		if err := write(p); err != nil {
+			return err
+		}
+		if n++; n%exportFlushEvery == 0 {
+			if err := finish(); err != nil {
+				return err
+			}
+			flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = finish()
+	}
+	if err != nil {
+		// The status line is already sent; the truncated body is all we can signal.
+		log.Printf("Product export failed after %d rows: %v", n, err)
+		return
+	}
+	log.Printf("Products exported format=%s rows=%d", format, n)
+}
+
+// importFormat picks the upload format from the format query parameter or the
+// Content-Type header.
+func importFormat(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		if format == models.FormatCSV || format == models.FormatNDJSON {
+			return format
+		}
+		return ""
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return models.FormatCSV
+	case "application/x-ndjson", "application/jsonl", "application/json-lines":
+		return models.FormatNDJSON
+	}
+	return ""
+}
+
+// readCSVRows parses a CSV upload whose first line is a header naming the columns.
+// Malformed CSV fails the whole upload; per-row value errors are kept on the row.
+func readCSVRows(body io.Reader) ([]importRow, error) {
+	cr := csv.NewReader(body)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("CSV upload is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %v", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, required := range []string{"name", "price"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV header must include a %s column", required)
+		}
+	}
+
+	var rows []importRow
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %v", err)
+		}
+		line, _ := cr.FieldPos(0)
+		in, err := models.ProductFromCSV(columns, record)
+		rows = append(rows, importRow{line: line, in: in, err: err})
+	}
+}
+
+// readNDJSONRows parses one product JSON object per line, skipping blank lines.
+func readNDJSONRows(body io.Reader) ([]importRow, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxNDJSONLineSize)
+
+	var rows []importRow
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var in models.ProductInput
+		err := json.Unmarshal([]byte(text), &in)
+		if err != nil {
+			err = fmt.Errorf("invalid JSON: %v", err)
+		}
+		rows = append(rows, importRow{line: line, in: in, err: err})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("invalid NDJSON: %v", err)
+	}
+	return rows, nil
+}
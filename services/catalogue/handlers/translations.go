@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/metalbear-co/metalmart/services/catalogue/models"
+)
+
+func (h *Handler) ListTranslations(w http.ResponseWriter, r *http.Request) {
+	translations, err := h.store.ListTranslations(mux.Vars(r)["id"])
+	if err != nil {
+		respondStoreError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, translations)
+}
+
+// SetTranslation creates or replaces the name and description of a product in the
+// locale from the path.
+func (h *Handler) SetTranslation(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	productID, locale := vars["id"], models.NormalizeLocale(vars["locale"])
+
+	var in models.TranslationInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	in.Normalize()
+	if err := in.Validate(locale); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	translation, err := h.store.SetTranslation(productID, locale, in)
+	if err != nil {
+		respondStoreError(w, err)
+		return
+	}
+
+	log.Printf("Translation updated product_id=%s locale=%s", productID, locale)
+	h.publishProduct(models.EventProductUpdated, productID)
+	respondJSON(w, http.StatusOK, translation)
+}
+
+func (h *Handler) DeleteTranslation(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	productID, locale := vars["id"], models.NormalizeLocale(vars["locale"])
+
+	if err := h.store.DeleteTranslation(productID, locale); err != nil {
+		respondStoreError(w, err)
+		return
+	}
+
+	log.Printf("Translation deleted product_id=%s locale=%s", productID, locale)
+	h.publishProduct(models.EventProductUpdated, productID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requestLocales returns the translations to serve, most preferred first: the locale
+// query parameter, else the Accept-Language header. nil means the default locale.
+func requestLocales(r *http.Request) ([]string, error) {
+	if locale := r.URL.Query().Get("locale"); locale != "" {
+		locale = models.NormalizeLocale(locale)
+		if !models.ValidLocale(locale) {
+			return nil, errors.New("locale must be a language tag such as de or pt-BR")
+		}
+		return models.LocaleCandidates(locale), nil
+	}
+	return models.LocaleCandidates(r.Header.Get("Accept-Language")), nil
+}
+
+// translate replaces product names and descriptions with their translations; it does
+// nothing when the default locale was requested.
+func (h *Handler) translate(locales []string, products ...*models.Product) error {
+	if len(locales) == 0 {
+		return nil
+	}
+	return h.store.Translate(locales, products...)
+}
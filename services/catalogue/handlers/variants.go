@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/metalbear-co/metalmart/services/catalogue/models"
+)
+
+func (h *Handler) ListVariants(w http.ResponseWriter, r *http.Request) {
+	productID := mux.Vars(r)["id"]
+
+	currency, err := requestCurrency(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	product, err := h.store.GetProduct(productID)
+	if err != nil {
+		respondStoreError(w, err)
+		return
+	}
+	if !product.Resolvable(time.Now()) {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.localize(currency, product); err != nil {
+		respondStoreError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, product.Variants)
+}
+
+func (h *Handler) CreateVariant(w http.ResponseWriter, r *http.Request) {
+	productID := mux.Vars(r)["id"]
+
+	var in models.VariantInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	in.Normalize()
+	if err := in.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	variant, err := h.store.CreateVariant(productID, in)
+	if err != nil {
+		respondStoreError(w, err)
+		return
+	}
+
+	log.Printf("Variant created product_id=%s variant_id=%s sku=%s", productID, variant.ID, variant.SKU)
+	h.publishProduct(models.EventProductUpdated, productID)
+	respondJSON(w, http.StatusCreated, variant)
+}
+
+func (h *Handler) ReplaceVariant(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	productID, variantID := vars["id"], vars["variantId"]
+
+	var in models.VariantInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	in.Normalize()
+	if in.ID != "" && in.ID != variantID {
+		http.Error(w, "id in body does not match id in path", http.StatusBadRequest)
+		return
+	}
+	if err := in.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	variant, err := h.store.UpdateVariant(productID, variantID, in)
+	if err != nil {
+		respondStoreError(w, err)
+		return
+	}
+
+	log.Printf("Variant replaced product_id=%s variant_id=%s", productID, variantID)
+	h.publishProduct(models.EventProductUpdated, productID)
+	respondJSON(w, http.StatusOK, variant)
+}
+
+func (h *Handler) DeleteVariant(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	productID, variantID := vars["id"], vars["variantId"]
+
+	if err := h.store.DeleteVariant(productID, variantID); err != nil {
+		respondStoreError(w, err)
+		return
+	}
+
+	log.Printf("Variant deleted product_id=%s variant_id=%s", productID, variantID)
+	h.publishProduct(models.EventProductUpdated, productID)
+	w.WriteHeader(http.StatusNoContent)
+}
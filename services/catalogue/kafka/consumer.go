@@ -0,0 +1,102 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/metalbear-co/metalmart/services/catalogue/models"
+	"github.com/metalbear-co/metalmart/services/catalogue/store"
+)
+
+const orderCreatedTopic = "order.created"
+
+// recordRetryDelay is the wait before an order that failed to record is retried.
+const recordRetryDelay = 5 * time.Second
+
+// OrderConsumer feeds the co-purchase statistics behind related products from the
+// order service's order.created events.
+type OrderConsumer struct {
+	group sarama.ConsumerGroup
+	store *store.PostgresStore
+}
+
+func NewOrderConsumer(brokers, groupID string, s *store.PostgresStore) (*OrderConsumer, error) {
+	config := sarama.NewConfig()
+	config.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategyRoundRobin()}
+	// A new group replays retained orders so recommendations start from the order history.
+	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+
+	group, err := sarama.NewConsumerGroup(strings.Split(brokers, ","), groupID, config)
+	if err != nil {
+		return nil, err
+	}
+	return &OrderConsumer{group: group, store: s}, nil
+}
+
+// Run consumes until ctx is cancelled, retrying after errors.
+func (c *OrderConsumer) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+			if err := c.group.Consume(ctx, []string{orderCreatedTopic}, c); err != nil {
+				log.Printf("Error consuming order events: %v", err)
+				time.Sleep(5 * time.Second)
+			}
+		}
+	}
+}
+
+func (c *OrderConsumer) Close() error {
+	return c.group.Close()
+}
+
+func (c *OrderConsumer) Setup(_ sarama.ConsumerGroupSession) error   { return nil }
+func (c *OrderConsumer) Cleanup(_ sarama.ConsumerGroupSession) error { return nil }
+
+func (c *OrderConsumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for msg := range claim.Messages() {
+		var event models.OrderCreatedEvent
+		if err := json.Unmarshal(msg.Value, &event); err != nil {
+			log.Printf("Failed to unmarshal order event: offset=%d: %v", msg.Offset, err)
+			session.MarkMessage(msg, "")
+			continue
+		}
+
+		// Events published before they carried items say nothing about the order, and
+		// marking it as seen would keep the backfill from recording it.
+		if len(event.Items) == 0 {
+			log.Printf("Skipping order event without items: order_id=%s offset=%d", event.OrderID, msg.Offset)
+			session.MarkMessage(msg, "")
+			continue
+		}
+		productIDs := make([]string, len(event.Items))
+		for i, item := range event.Items {
+			productIDs[i] = item.ProductID
+		}
+		// Single-product orders still mark the order as seen. Store errors are retried in
+		// place so the event is only marked once the order is recorded.
+		for {
+			recorded, err := c.store.RecordOrder(event.OrderID, productIDs)
+			if err == nil {
+				if recorded {
+					log.Printf("Recorded co-purchases of order %s products=%d", event.OrderID, len(productIDs))
+				}
+				break
+			}
+			log.Printf("Failed to record co-purchases of order %s, retrying: %v", event.OrderID, err)
+			select {
+			case <-session.Context().Done():
+				return nil
+			case <-time.After(recordRetryDelay):
+			}
+		}
+		session.MarkMessage(msg, "")
+	}
+	return nil
+}
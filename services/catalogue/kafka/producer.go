@@ -0,0 +1,50 @@
+package kafka
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/IBM/sarama"
+	"github.com/metalbear-co/metalmart/services/catalogue/models"
+)
+
+type Producer struct {
+	producer sarama.SyncProducer
+}
+
+func NewProducer(brokers string) (*Producer, error) {
+	config := sarama.NewConfig()
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Retry.Max = 5
+	config.Producer.Return.Successes = true
+
+	brokerList := strings.Split(brokers, ",")
+	producer, err := sarama.NewSyncProducer(brokerList, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Producer{producer: producer}, nil
+}
+
+func (p *Producer) Close() error {
+	return p.producer.Close()
+}
+
+// PublishProductEvent sends event to the topic named by its type, keyed by product id
+// so all changes to one product stay ordered on a single partition.
+func (p *Producer) PublishProductEvent(event models.ProductEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	msg := &sarama.ProducerMessage{
+		Topic: event.Type,
+		Key:   sarama.StringEncoder(event.ProductID),
+		Value: sarama.ByteEncoder(data),
+	}
+
+	_, _, err = p.producer.SendMessage(msg)
+	return err
+}
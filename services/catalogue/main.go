@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/metalbear-co/metalmart/services/catalogue/backfill"
 	"github.com/metalbear-co/metalmart/services/catalogue/handlers"
+	"github.com/metalbear-co/metalmart/services/catalogue/kafka"
 	"github.com/metalbear-co/metalmart/services/catalogue/store"
 )
 
@@ -37,7 +41,49 @@ func main() {
 		}
 	}
 
-	h := handlers.NewHandler(db)
+	cacheTTL := 30 * time.Second
+	if v := os.Getenv("CACHE_TTL"); v != "" {
+		if cacheTTL, err = time.ParseDuration(v); err != nil {
+			log.Fatalf("Invalid CACHE_TTL %q: %v", v, err)
+		}
+	}
+
+	kafkaBrokers := os.Getenv("KAFKA_BROKERS")
+	if kafkaBrokers == "" {
+		kafkaBrokers = "localhost:9092"
+	}
+
+	producer, err := kafka.NewProducer(kafkaBrokers)
+	if err != nil {
+		log.Printf("Warning: Failed to connect to Kafka: %v", err)
+		producer = nil
+	}
+	if producer != nil {
+		defer producer.Close()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	kafkaGroupID := os.Getenv("KAFKA_GROUP_ID")
+	if kafkaGroupID == "" {
+		kafkaGroupID = "catalogue"
+	}
+	consumer, err := kafka.NewOrderConsumer(kafkaBrokers, kafkaGroupID, db)
+	if err != nil {
+		log.Printf("Warning: Failed to connect to Kafka, related products will not learn from new orders: %v", err)
+	} else {
+		defer consumer.Close()
+		go consumer.Run(ctx)
+	}
+
+	orderURL := os.Getenv("ORDER_SERVICE_URL")
+	if orderURL == "" {
+		orderURL = "http://order:8084"
+	}
+	go backfill.Copurchases(ctx, db, orderURL)
+
+	h := handlers.NewHandler(db, cacheTTL, producer, orderURL)
 
 	r := mux.NewRouter()
 
@@ -49,10 +95,56 @@ func main() {
 
 	// API routes
 	api := r.PathPrefix("/api").Subrouter()
-	api.HandleFunc("/products", h.ListProducts).Methods("GET")
-	api.HandleFunc("/products/search", h.SearchProducts).Methods("GET")
-	api.HandleFunc("/products/category/{category}", h.ListByCategory).Methods("GET")
-	api.HandleFunc("/products/{id}", h.GetProduct).Methods("GET")
+	api.Use(h.InvalidateOnWrite)
+	api.HandleFunc("/products", h.Cached(h.ListProducts)).Methods("GET")
+	api.HandleFunc("/products/search", h.Cached(h.SearchProducts)).Methods("GET")
+	api.HandleFunc("/products/suggest", h.Cached(h.Suggest)).Methods("GET")
+	api.HandleFunc("/products/export", h.ExportProducts).Methods("GET")
+	api.HandleFunc("/products/category/{category}", h.Cached(h.ListByCategory)).Methods("GET")
+	api.HandleFunc("/products/{id}", h.Cached(h.GetProduct)).Methods("GET")
+	api.HandleFunc("/products/{id}/variants", h.Cached(h.ListVariants)).Methods("GET")
+	api.HandleFunc("/products/{id}/media", h.Cached(h.ListMedia)).Methods("GET")
+	api.HandleFunc("/products/{id}/related", h.Cached(h.RelatedProducts)).Methods("GET")
+	api.HandleFunc("/products/{id}/reviews", h.Cached(h.ListReviews)).Methods("GET")
+	api.HandleFunc("/products/{id}/reviews", h.CreateReview).Methods("POST")
+	api.HandleFunc("/currencies", h.Cached(h.ListCurrencyRates)).Methods("GET")
+	api.HandleFunc("/categories", h.Cached(h.ListCategories)).Methods("GET")
+
+	// Admin routes
+	api.HandleFunc("/products", h.CreateProduct).Methods("POST")
+	api.HandleFunc("/products/import", h.ImportProducts).Methods("POST")
+	api.HandleFunc("/products/{id}", h.ReplaceProduct).Methods("PUT")
+	api.HandleFunc("/products/{id}", h.PatchProduct).Methods("PATCH")
+	api.HandleFunc("/products/{id}", h.DeleteProduct).Methods("DELETE")
+	api.HandleFunc("/products/{id}/variants", h.CreateVariant).Methods("POST")
+	api.HandleFunc("/products/{id}/variants/{variantId}", h.ReplaceVariant).Methods("PUT")
+	api.HandleFunc("/products/{id}/variants/{variantId}", h.DeleteVariant).Methods("DELETE")
+	api.HandleFunc("/products/{id}/media", h.AddMedia).Methods("POST")
+	api.HandleFunc("/products/{id}/media/order", h.ReorderMedia).Methods("PUT")
+	api.HandleFunc("/products/{id}/media/{mediaId}", h.ReplaceMedia).Methods("PUT")
+	api.HandleFunc("/products/{id}/media/{mediaId}", h.DeleteMedia).Methods("DELETE")
+	api.HandleFunc("/products/{id}/prices", h.GetPriceList).Methods("GET")
+	api.HandleFunc("/products/{id}/prices/{currency}", h.SetListPrice).Methods("PUT")
+	api.HandleFunc("/products/{id}/prices/{currency}", h.DeleteListPrice).Methods("DELETE")
+	api.HandleFunc("/products/{id}/price-history", h.GetPriceHistory).Methods("GET")
+	api.HandleFunc("/products/{id}/scheduled-prices", h.SchedulePrice).Methods("POST")
+	api.HandleFunc("/products/{id}/scheduled-prices/{priceId}", h.CancelScheduledPrice).Methods("DELETE")
+	api.HandleFunc("/products/{id}/translations", h.ListTranslations).Methods("GET")
+	api.HandleFunc("/products/{id}/translations/{locale}", h.SetTranslation).Methods("PUT")
+	api.HandleFunc("/products/{id}/translations/{locale}", h.DeleteTranslation).Methods("DELETE")
+	api.HandleFunc("/currencies/{currency}", h.SetCurrencyRate).Methods("PUT")
+	api.HandleFunc("/currencies/{currency}", h.DeleteCurrencyRate).Methods("DELETE")
+	api.HandleFunc("/categories", h.CreateCategory).Methods("POST")
+	api.HandleFunc("/categories/{slug}", h.ReplaceCategory).Methods("PUT")
+	api.HandleFunc("/categories/{slug}", h.DeleteCategory).Methods("DELETE")
+	api.HandleFunc("/admin/products", h.AdminListProducts).Methods("GET")
+	api.HandleFunc("/admin/products/{id}", h.AdminGetProduct).Methods("GET")
+	api.HandleFunc("/reviews", h.AdminListReviews).Methods("GET")
+	api.HandleFunc("/reviews/{reviewId}/status", h.ModerateReview).Methods("PUT")
+	api.HandleFunc("/merchandising", h.GetMerchandising).Methods("GET")
+	api.HandleFunc("/merchandising/order", h.ReorderProducts).Methods("PUT")
+	api.HandleFunc("/merchandising/featured", h.SetFeatured).Methods("PUT")
+	api.HandleFunc("/merchandising/categories/{category}", h.RankCategory).Methods("PUT")
 
 	// CORS middleware
 	handler := corsMiddleware(r)
@@ -66,8 +158,9 @@ func main() {
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Accept-Currency, Accept-Language, If-None-Match, If-Modified-Since")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag, Last-Modified")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
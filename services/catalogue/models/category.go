@@ -0,0 +1,50 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Category is a node of the category tree. Products reference categories by slug;
+// listing a category includes the products of all its descendants.
+type Category struct {
+	Slug       string     `json:"slug"`
+	ParentSlug string     `json:"parent_slug,omitempty"`
+	Name       string     `json:"name"`
+	SortOrder  int        `json:"sort_order"`
+	Children   []Category `json:"children"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CategoryInput is the body accepted by POST /api/categories and PUT /api/categories/{slug}.
+type CategoryInput struct {
+	Slug       string `json:"slug"`
+	ParentSlug string `json:"parent_slug"`
+	Name       string `json:"name"`
+	SortOrder  int    `json:"sort_order"`
+}
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+func (in *CategoryInput) Normalize() {
+	in.Slug = strings.ToLower(strings.TrimSpace(in.Slug))
+	in.ParentSlug = strings.ToLower(strings.TrimSpace(in.ParentSlug))
+	in.Name = strings.TrimSpace(in.Name)
+}
+
+func (in CategoryInput) Validate() error {
+	if !slugPattern.MatchString(in.Slug) || len(in.Slug) > 100 {
+		return validationError("slug must be 1 to 100 lower-case letters, digits and single hyphens")
+	}
+	if in.ParentSlug == in.Slug {
+		return validationError("a category cannot be its own parent")
+	}
+	if in.Name == "" {
+		return validationError("name is required")
+	}
+	if len(in.Name) > 255 {
+		return validationError("name must be at most 255 characters")
+	}
+	return nil
+}
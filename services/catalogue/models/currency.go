@@ -0,0 +1,74 @@
+package models
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/metalbear-co/metalmart/pkg/money"
+)
+
+// Rounding modes applied to converted prices.
+const (
+	RoundNearest = "nearest"
+	RoundUp      = "up"
+	RoundDown    = "down"
+)
+
+// CurrencyRate is the exchange rate of a currency against DefaultCurrency (units of
+// Currency per one unit of DefaultCurrency) and how converted prices are rounded.
+// Increment is in minor units: 1 rounds to the cent, 50 to the nearest half unit.
+type CurrencyRate struct {
+	Currency  string      `json:"currency"`
+	Rate      json.Number `json:"rate"`
+	Rounding  string      `json:"rounding"`
+	Increment int64       `json:"increment"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// Normalize fills in the default rounding rule.
+func (r *CurrencyRate) Normalize() {
+	r.Currency = money.NormalizeCurrency(r.Currency)
+	r.Rounding = strings.ToLower(strings.TrimSpace(r.Rounding))
+	if r.Rounding == "" {
+		r.Rounding = RoundNearest
+	}
+	if r.Increment == 0 {
+		r.Increment = 1
+	}
+}
+
+func (r CurrencyRate) Validate() error {
+	if !money.ValidCurrency(r.Currency) {
+		return validationError("currency must be a three-letter ISO 4217 code")
+	}
+	if r.Currency == money.DefaultCurrency {
+		return validationError("%s is the base currency and has no rate", money.DefaultCurrency)
+	}
+	if rate, ok := new(big.Rat).SetString(r.Rate.String()); !ok || rate.Sign() <= 0 {
+		return validationError("rate must be a positive decimal number")
+	}
+	switch r.Rounding {
+	case RoundNearest, RoundUp, RoundDown:
+	default:
+		return validationError("rounding must be one of %s, %s, %s", RoundNearest, RoundUp, RoundDown)
+	}
+	if r.Increment < 1 {
+		return validationError("increment must be at least 1")
+	}
+	return nil
+}
+
+// PriceListEntry is the body of the product price list PUT endpoint: a fixed price in
+// the currency from the path that overrides rate conversion.
+type PriceListEntry struct {
+	Amount int64 `json:"amount"`
+}
+
+func (e PriceListEntry) Validate() error {
+	if e.Amount < 0 {
+		return validationError("amount must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Product change events, published on topics of the same name.
+const (
+	EventProductCreated = "product.created"
+	EventProductUpdated = "product.updated"
+	EventProductDeleted = "product.deleted"
+)
+
+// ProductEvent describes a change to a product. Product is the state after the change,
+// including variants; it is omitted for product.deleted.
+type ProductEvent struct {
+	Type       string    `json:"type"`
+	ProductID  string    `json:"product_id"`
+	Product    *Product  `json:"product,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// OrderCreatedEvent is the part of the order service's order.created event the
+// catalogue uses to learn which products are bought together.
+type OrderCreatedEvent struct {
+	OrderID string `json:"order_id"`
+	Items   []struct {
+		ProductID string `json:"product_id"`
+	} `json:"items"`
+	CreatedAt time.Time `json:"created_at"`
+}
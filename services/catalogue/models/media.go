@@ -0,0 +1,86 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Media roles. A product has at most one primary image, which is also served as the
+// product's image_url. Media without a role are plain gallery images.
+const (
+	MediaPrimary   = "primary"
+	MediaThumbnail = "thumbnail"
+	MediaLifestyle = "lifestyle"
+)
+
+// Media is an image in a product's gallery, ordered by Position. Width and Height are
+// in pixels and omitted when unknown.
+type Media struct {
+	ID        int64     `json:"id"`
+	ProductID string    `json:"product_id"`
+	URL       string    `json:"url"`
+	AltText   string    `json:"alt_text"`
+	Width     *int      `json:"width,omitempty"`
+	Height    *int      `json:"height,omitempty"`
+	Role      string    `json:"role,omitempty"`
+	Position  int       `json:"position"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MediaInput is the body accepted by POST /api/products/{id}/media and
+// PUT /api/products/{id}/media/{mediaId}. A nil Position appends the media to the gallery.
+type MediaInput struct {
+	URL      string `json:"url"`
+	AltText  string `json:"alt_text"`
+	Width    *int   `json:"width"`
+	Height   *int   `json:"height"`
+	Role     string `json:"role"`
+	Position *int   `json:"position"`
+}
+
+func (in *MediaInput) Normalize() {
+	in.URL = strings.TrimSpace(in.URL)
+	in.AltText = strings.TrimSpace(in.AltText)
+	in.Role = strings.ToLower(strings.TrimSpace(in.Role))
+}
+
+func (in MediaInput) Validate() error {
+	if in.URL == "" {
+		return validationError("url is required")
+	}
+	if len(in.URL) > 500 {
+		return validationError("url must be at most 500 characters")
+	}
+	if len(in.AltText) > 500 {
+		return validationError("alt_text must be at most 500 characters")
+	}
+	if (in.Width != nil && *in.Width <= 0) || (in.Height != nil && *in.Height <= 0) {
+		return validationError("width and height must be positive")
+	}
+	switch in.Role {
+	case "", MediaPrimary, MediaThumbnail, MediaLifestyle:
+	default:
+		return validationError("role must be one of %s, %s, %s", MediaPrimary, MediaThumbnail, MediaLifestyle)
+	}
+	if in.Position != nil && *in.Position < 0 {
+		return validationError("position must not be negative")
+	}
+	return nil
+}
+
+// MediaOrder is the body of PUT /api/products/{id}/media/order. Listed media come
+// first in the given order; the rest keep their relative order after them.
+type MediaOrder struct {
+	MediaIDs []int64 `json:"media_ids"`
+}
+
+func (o MediaOrder) Validate() error {
+	seen := make(map[int64]bool, len(o.MediaIDs))
+	for _, id := range o.MediaIDs {
+		if seen[id] {
+			return validationError("media id %d is listed more than once", id)
+		}
+		seen[id] = true
+	}
+	return nil
+}
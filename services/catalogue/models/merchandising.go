@@ -0,0 +1,29 @@
+package models
+
+// Merchandising is the current storefront ordering: the global display order,
+// the pinned featured products and any per-category rankings.
+type Merchandising struct {
+	Order            []string            `json:"order"`
+	Featured         []string            `json:"featured"`
+	CategoryRankings map[string][]string `json:"category_rankings"`
+}
+
+// RankingRequest is the body of the merchandising PUT endpoints. The position of
+// a product ID in the list is its rank (first = shown first).
+type RankingRequest struct {
+	ProductIDs []string `json:"product_ids"`
+}
+
+func (r RankingRequest) Validate() error {
+	seen := make(map[string]bool, len(r.ProductIDs))
+	for _, id := range r.ProductIDs {
+		if id == "" {
+			return validationError("product_ids must not contain empty ids")
+		}
+		if seen[id] {
+			return validationError("product id %s is listed more than once", id)
+		}
+		seen[id] = true
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/metalbear-co/metalmart/pkg/money"
+)
+
+// Kinds of price history entries. A list entry records a change of the product's own
+// price; a scheduled entry overrides it between EffectiveFrom and EffectiveTo.
+const (
+	PriceKindList      = "list"
+	PriceKindScheduled = "scheduled"
+)
+
+// PriceChange is one entry of a product's price history. EffectiveTo is nil while the
+// entry is open-ended. CompareAt is the "was" price shown next to a scheduled price.
+type PriceChange struct {
+	ID            int64        `json:"id"`
+	ProductID     string       `json:"product_id"`
+	Kind          string       `json:"kind"`
+	Price         money.Money  `json:"price"`
+	CompareAt     *money.Money `json:"compare_at,omitempty"`
+	EffectiveFrom time.Time    `json:"effective_from"`
+	EffectiveTo   *time.Time   `json:"effective_to,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+}
+
+// Active reports whether the entry is in effect at now.
+func (c PriceChange) Active(now time.Time) bool {
+	return !c.EffectiveFrom.After(now) && (c.EffectiveTo == nil || c.EffectiveTo.After(now))
+}
+
+// ScheduledPriceInput is the body accepted by POST /api/products/{id}/scheduled-prices.
+// Currencies default to the product's and must match it: price list entries cover other
+// currencies. An omitted effective_from starts the price immediately; an omitted
+// effective_to keeps it until cancelled.
+type ScheduledPriceInput struct {
+	Price         money.Money  `json:"price"`
+	CompareAt     *money.Money `json:"compare_at"`
+	EffectiveFrom *time.Time   `json:"effective_from"`
+	EffectiveTo   *time.Time   `json:"effective_to"`
+}
+
+// Normalize fills omitted currencies with currency, the product's, and an omitted
+// effective_from with now.
+func (in *ScheduledPriceInput) Normalize(currency string, now time.Time) {
+	in.Price.Currency = strings.ToUpper(strings.TrimSpace(in.Price.Currency))
+	if in.Price.Currency == "" {
+		in.Price.Currency = currency
+	}
+	if in.CompareAt != nil {
+		in.CompareAt.Currency = strings.ToUpper(strings.TrimSpace(in.CompareAt.Currency))
+		if in.CompareAt.Currency == "" {
+			in.CompareAt.Currency = in.Price.Currency
+		}
+	}
+	if in.EffectiveFrom == nil {
+		in.EffectiveFrom = &now
+	}
+}
+
+// Validate checks the input against the product's currency and the current time.
+func (in ScheduledPriceInput) Validate(currency string, now time.Time) error {
+	if in.Price.Amount <= 0 {
+		return validationError("price.amount must be greater than zero")
+	}
+	if in.Price.Currency != currency {
+		return validationError("price.currency must be the product currency %s", currency)
+	}
+	if in.CompareAt != nil {
+		if in.CompareAt.Currency != in.Price.Currency {
+			return validationError("compare_at.currency must match price.currency")
+		}
+		if in.CompareAt.Amount <= in.Price.Amount {
+			return validationError("compare_at.amount must be greater than price.amount")
+		}
+	}
+	if in.EffectiveTo != nil {
+		if !in.EffectiveTo.After(*in.EffectiveFrom) {
+			return validationError("effective_to must be after effective_from")
+		}
+		if !in.EffectiveTo.After(now) {
+			return validationError("effective_to must be in the future")
+		}
+	}
+	return nil
+}
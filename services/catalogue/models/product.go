@@ -1,13 +1,225 @@
 package models
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
+	"github.com/metalbear-co/metalmart/pkg/money"
+)
+
+// Product is a catalogue entry. Price is the price in effect now: a scheduled price
+// while one is active, otherwise ListPrice, the price set on the product itself.
+// CompareAt is the original price shown while a scheduled price is a discount.
+// Rating is the average of the approved reviews, 0 when there are none. Locale is set
+// when Name and Description were replaced by a translation. ImageURL is the url of
+// the primary image in Media.
 type Product struct {
-	ID          string    `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Price       float64   `json:"price"`
-	ImageURL    string    `json:"image_url"`
-	Category    string    `json:"category"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID          string                 `json:"id"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Locale      string                 `json:"locale,omitempty"`
+	Price       money.Money            `json:"price"`
+	CompareAt   *money.Money           `json:"compare_at,omitempty"`
+	ListPrice   money.Money            `json:"-"`
+	ImageURL    string                 `json:"image_url"`
+	Category    string                 `json:"category"`
+	Attributes  map[string]interface{} `json:"attributes"`
+	Status      string                 `json:"status"`
+	PublishAt   *time.Time             `json:"publish_at,omitempty"`
+	UnpublishAt *time.Time             `json:"unpublish_at,omitempty"`
+	Variants    []Variant              `json:"variants,omitempty"`
+	Media       []Media                `json:"media,omitempty"`
+	Rating      float64                `json:"rating"`
+	ReviewCount int                    `json:"review_count"`
+	CreatedAt   time.Time              `json:"created_at"`
+	UpdatedAt   time.Time              `json:"updated_at"`
+}
+
+// Product lifecycle states. Only published products inside their publish_at/unpublish_at
+// window are shown on the storefront; archived products are hidden from listings but
+// can still be fetched by id so past orders resolve.
+const (
+	StatusDraft     = "draft"
+	StatusPublished = "published"
+	StatusArchived  = "archived"
+)
+
+// Live reports whether the product is visible on the storefront at now.
+func (p Product) Live(now time.Time) bool {
+	return p.Status == StatusPublished &&
+		(p.PublishAt == nil || !p.PublishAt.After(now)) &&
+		(p.UnpublishAt == nil || p.UnpublishAt.After(now))
+}
+
+// Resolvable reports whether the public product endpoint returns the product at now.
+func (p Product) Resolvable(now time.Time) bool {
+	return p.Live(now) || p.Status == StatusArchived
+}
+
+const (
+	SortPriceAsc  = "price_asc"
+	SortPriceDesc = "price_desc"
+	SortNewest    = "newest"
+	SortName      = "name"
+
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
+
+// ListOptions controls paging and ordering of the product listing endpoints. An empty
+// Sort keeps the merchandised order.
+type ListOptions struct {
+	Limit  int
+	Cursor string
+	Sort   string
+}
+
+// ProductPage is the response envelope of the product listing endpoints.
+type ProductPage struct {
+	Products   []Product `json:"products"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+	Total      int       `json:"total"`
+}
+
+// ProductInput is the body accepted by POST /api/products and PUT /api/products/{id}.
+type ProductInput struct {
+	ID          string                 `json:"id,omitempty"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Price       money.Money            `json:"price"`
+	ImageURL    string                 `json:"image_url"`
+	Category    string                 `json:"category"`
+	Attributes  map[string]interface{} `json:"attributes,omitempty"`
+	Status      string                 `json:"status,omitempty"`
+	PublishAt   *time.Time             `json:"publish_at,omitempty"`
+	UnpublishAt *time.Time             `json:"unpublish_at,omitempty"`
+}
+
+// ProductPatch is the body accepted by PATCH /api/products/{id}. Nil fields are left
+// unchanged, so a schedule can only be cleared with PUT.
+type ProductPatch struct {
+	Name        *string                `json:"name"`
+	Description *string                `json:"description"`
+	Price       *money.Money           `json:"price"`
+	ImageURL    *string                `json:"image_url"`
+	Category    *string                `json:"category"`
+	Attributes  map[string]interface{} `json:"attributes"`
+	Status      *string                `json:"status"`
+	PublishAt   *time.Time             `json:"publish_at"`
+	UnpublishAt *time.Time             `json:"unpublish_at"`
+}
+
+// ErrValidation is wrapped by every error returned from Validate so handlers can map it to 400.
+var ErrValidation = errors.New("validation failed")
+
+func validationError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrValidation, fmt.Sprintf(format, args...))
+}
+
+func (in *ProductInput) Normalize() {
+	in.ID = strings.TrimSpace(in.ID)
+	in.Name = strings.TrimSpace(in.Name)
+	in.ImageURL = strings.TrimSpace(in.ImageURL)
+	in.Category = strings.TrimSpace(in.Category)
+	in.Price.Currency = money.NormalizeCurrency(in.Price.Currency)
+	// An omitted status stays empty: a new product is published, as products created
+	// before lifecycle states existed were always live, and an existing one keeps its
+	// status.
+	in.Status = strings.ToLower(strings.TrimSpace(in.Status))
+}
+
+// Validate checks the input against the limits of the products table.
+func (in ProductInput) Validate() error {
+	if len(in.ID) > 50 {
+		return validationError("id must be at most 50 characters")
+	}
+	if strings.ContainsAny(in.ID, "/?#") {
+		return validationError("id must not contain '/', '?' or '#'")
+	}
+	if in.Name == "" {
+		return validationError("name is required")
+	}
+	if len(in.Name) > 255 {
+		return validationError("name must be at most 255 characters")
+	}
+	if in.Price.Amount <= 0 {
+		return validationError("price.amount must be greater than zero")
+	}
+	if !money.ValidCurrency(in.Price.Currency) {
+		return validationError("price.currency must be an ISO 4217 code such as USD")
+	}
+	if len(in.ImageURL) > 500 {
+		return validationError("image_url must be at most 500 characters")
+	}
+	if len(in.Category) > 100 {
+		return validationError("category must be at most 100 characters")
+	}
+	for key, value := range in.Attributes {
+		if key == "" || len(key) > 64 {
+			return validationError("attribute names must be 1 to 64 characters")
+		}
+		switch value.(type) {
+		case string, float64, bool:
+		default:
+			return validationError("attribute %s must be a string, number or boolean", key)
+		}
+	}
+	switch in.Status {
+	case "", StatusDraft, StatusPublished, StatusArchived:
+	default:
+		return validationError("status must be one of %s, %s, %s", StatusDraft, StatusPublished, StatusArchived)
+	}
+	if in.PublishAt != nil && in.UnpublishAt != nil && !in.UnpublishAt.After(*in.PublishAt) {
+		return validationError("unpublish_at must be after publish_at")
+	}
+	return nil
+}
+
+// Apply overlays the fields set in the patch onto p.
+func (patch ProductPatch) Apply(p Product) ProductInput {
+	in := ProductInput{
+		ID:          p.ID,
+		Name:        p.Name,
+		Description: p.Description,
+		Price:       p.ListPrice,
+		ImageURL:    p.ImageURL,
+		Category:    p.Category,
+		Attributes:  p.Attributes,
+		Status:      p.Status,
+		PublishAt:   p.PublishAt,
+		UnpublishAt: p.UnpublishAt,
+	}
+	if patch.Name != nil {
+		in.Name = *patch.Name
+	}
+	if patch.Description != nil {
+		in.Description = *patch.Description
+	}
+	if patch.Price != nil {
+		in.Price.Amount = patch.Price.Amount
+		if patch.Price.Currency != "" {
+			in.Price.Currency = patch.Price.Currency
+		}
+	}
+	if patch.ImageURL != nil {
+		in.ImageURL = *patch.ImageURL
+	}
+	if patch.Category != nil {
+		in.Category = *patch.Category
+	}
+	if patch.Attributes != nil {
+		in.Attributes = patch.Attributes
+	}
+	if patch.Status != nil {
+		in.Status = *patch.Status
+	}
+	if patch.PublishAt != nil {
+		in.PublishAt = patch.PublishAt
+	}
+	if patch.UnpublishAt != nil {
+		in.UnpublishAt = patch.UnpublishAt
+	}
+	return in
 }
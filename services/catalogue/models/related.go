@@ -0,0 +1,31 @@
+package models
+
+// Reasons a product is recommended alongside another.
+const (
+	RelatedBoughtTogether = "bought_together"
+	RelatedSameCategory   = "same_category"
+)
+
+const (
+	DefaultRelatedLimit = 8
+	MaxRelatedLimit     = 50
+)
+
+// RelatedProduct is a recommendation for a product page. CoPurchases is the number of
+// orders that contained both products.
+type RelatedProduct struct {
+	Product
+	Reason      string `json:"reason"`
+	CoPurchases int    `json:"co_purchases"`
+}
+
+// OrderProductsPage is a page of the order service's GET /api/orders/products: the
+// distinct products of each order, ordered by order id. NextCursor is empty on the
+// last page.
+type OrderProductsPage struct {
+	Orders []struct {
+		OrderID    string   `json:"order_id"`
+		ProductIDs []string `json:"product_ids"`
+	} `json:"orders"`
+	NextCursor string `json:"next_cursor"`
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// Review moderation states. New reviews wait for moderation; only approved reviews
+// are listed publicly and count towards a product's rating.
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
+
+// Review is a customer's rating of a product. VerifiedPurchase is set when the order
+// service has a shipped order from the author containing the product.
+type Review struct {
+	ID               string    `json:"id"`
+	ProductID        string    `json:"product_id"`
+	Rating           int       `json:"rating"`
+	Title            string    `json:"title,omitempty"`
+	Body             string    `json:"body"`
+	AuthorName       string    `json:"author_name"`
+	AuthorEmail      string    `json:"-"`
+	VerifiedPurchase bool      `json:"verified_purchase"`
+	Status           string    `json:"status"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// ReviewPage is the response envelope of the review listing endpoints.
+type ReviewPage struct {
+	Reviews    []Review `json:"reviews"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+	Total      int      `json:"total"`
+}
+
+// ReviewInput is the body accepted by POST /api/products/{id}/reviews.
+type ReviewInput struct {
+	Rating      int    `json:"rating"`
+	Title       string `json:"title"`
+	Body        string `json:"body"`
+	AuthorName  string `json:"author_name"`
+	AuthorEmail string `json:"author_email"`
+}
+
+func (in *ReviewInput) Normalize() {
+	in.Title = strings.TrimSpace(in.Title)
+	in.Body = strings.TrimSpace(in.Body)
+	in.AuthorName = strings.TrimSpace(in.AuthorName)
+	in.AuthorEmail = strings.ToLower(strings.TrimSpace(in.AuthorEmail))
+}
+
+func (in ReviewInput) Validate() error {
+	if in.Rating < 1 || in.Rating > 5 {
+		return validationError("rating must be between 1 and 5")
+	}
+	if len(in.Title) > 200 {
+		return validationError("title must be at most 200 characters")
+	}
+	if in.Body == "" {
+		return validationError("body is required")
+	}
+	if len(in.Body) > 5000 {
+		return validationError("body must be at most 5000 characters")
+	}
+	if in.AuthorName == "" || len(in.AuthorName) > 100 {
+		return validationError("author_name must be 1 to 100 characters")
+	}
+	if _, err := mail.ParseAddress(in.AuthorEmail); err != nil || len(in.AuthorEmail) > 255 {
+		return validationError("author_email must be a valid email address")
+	}
+	return nil
+}
+
+// ReviewModeration is the body accepted by PUT /api/reviews/{reviewId}/status.
+type ReviewModeration struct {
+	Status string `json:"status"`
+}
+
+func (m ReviewModeration) Validate() error {
+	switch m.Status {
+	case ReviewPending, ReviewApproved, ReviewRejected:
+		return nil
+	}
+	return validationError("status must be one of %s, %s, %s", ReviewPending, ReviewApproved, ReviewRejected)
+}
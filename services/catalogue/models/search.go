@@ -0,0 +1,134 @@
+package models
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/metalbear-co/metalmart/pkg/money"
+)
+
+const SortRelevance = "relevance"
+
+// SearchFilters are the query parameters of GET /api/products/search. Categories and
+// values of the same attribute are OR'ed; everything else is AND'ed. Prices are in
+// minor units. Locales are the translation candidates the text query also matches.
+// AttributeRanges bound numeric attributes, e.g. attr.capacity_oz=12..20.
+type SearchFilters struct {
+	Query           string
+	Locales         []string
+	Categories      []string
+	MinPrice        *int64
+	MaxPrice        *int64
+	Attributes      map[string][]string
+	AttributeRanges map[string]AttributeRange
+}
+
+func (f SearchFilters) Validate() error {
+	if f.MinPrice != nil && f.MaxPrice != nil && *f.MinPrice > *f.MaxPrice {
+		return validationError("min_price must not be greater than max_price")
+	}
+	for name, r := range f.AttributeRanges {
+		if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+			return validationError("attr.%s range minimum must not be greater than its maximum", name)
+		}
+	}
+	return nil
+}
+
+// AttributeRange is an inclusive numeric bound on an attribute; a nil end is open.
+type AttributeRange struct {
+	Min *float64
+	Max *float64
+}
+
+// ParseAttributeRange parses "min..max", "min.." or "..max". ok is false when v is
+// not a range, in which case it is matched as a plain value.
+func ParseAttributeRange(v string) (r AttributeRange, ok bool) {
+	lo, hi, found := strings.Cut(v, "..")
+	if !found || (lo == "" && hi == "") {
+		return r, false
+	}
+	for _, end := range []struct {
+		text string
+		dst  **float64
+	}{{lo, &r.Min}, {hi, &r.Max}} {
+		if end.text == "" {
+			continue
+		}
+		n, err := strconv.ParseFloat(end.text, 64)
+		if err != nil || math.IsInf(n, 0) || math.IsNaN(n) {
+			return AttributeRange{}, false
+		}
+		*end.dst = &n
+	}
+	return r, true
+}
+
+// SearchHit is a product matched by search. When the search has a text query it
+// carries the relevance score and highlights: the name and description escaped for
+// HTML, with the matched terms wrapped in <mark> tags.
+type SearchHit struct {
+	Product
+	Score      float64           `json:"score,omitempty"`
+	Highlights *SearchHighlights `json:"highlights,omitempty"`
+}
+
+type SearchHighlights struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// SearchPage is the response envelope of GET /api/products/search.
+type SearchPage struct {
+	Products   []SearchHit  `json:"products"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+	Total      int          `json:"total"`
+	Facets     SearchFacets `json:"facets"`
+}
+
+// SearchFacets counts matching products per category and price range. Each facet is
+// computed with every filter applied except its own, so the counts show what selecting
+// another value would return.
+type SearchFacets struct {
+	Categories  []FacetCount  `json:"categories"`
+	PriceRanges []PriceBucket `json:"price_ranges"`
+}
+
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// PriceBucket counts products with Min <= price < Max. Max is omitted on the last bucket.
+type PriceBucket struct {
+	Min   money.Money  `json:"min"`
+	Max   *money.Money `json:"max,omitempty"`
+	Count int          `json:"count"`
+}
+
+const (
+	DefaultSuggestLimit = 8
+	MaxSuggestLimit     = 20
+)
+
+// Suggestions is the response of GET /api/products/suggest: product name completions
+// and matching categories for a partial query, best match first.
+type Suggestions struct {
+	Query      string               `json:"query"`
+	Products   []ProductSuggestion  `json:"products"`
+	Categories []CategorySuggestion `json:"categories"`
+}
+
+type ProductSuggestion struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name"`
+	Category string  `json:"category,omitempty"`
+	Score    float64 `json:"score"`
+}
+
+type CategorySuggestion struct {
+	Slug  string  `json:"slug"`
+	Name  string  `json:"name"`
+	Score float64 `json:"score"`
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/metalbear-co/metalmart/pkg/money"
+)
+
+// Bulk transfer formats accepted by the import endpoint and produced by the export endpoint.
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// CSVColumns is the header written by the CSV export and understood by the CSV import.
+// price is a decimal amount in major units ("19.99"); attributes is a JSON object;
+// publish_at and unpublish_at are RFC 3339 timestamps or empty.
+var CSVColumns = []string{"id", "name", "description", "price", "currency", "image_url", "category", "attributes", "status", "publish_at", "unpublish_at"}
+
+// ImportResult reports what an import did, or would do in a dry run. When Errors is
+// non-empty nothing was written.
+type ImportResult struct {
+	DryRun  bool          `json:"dry_run"`
+	Rows    int           `json:"rows"`
+	Created int           `json:"created"`
+	Updated int           `json:"updated"`
+	Errors  []ImportError `json:"errors"`
+}
+
+// ImportError is a problem with one input row. Row is the 1-based line number in the
+// uploaded file (the CSV header is line 1).
+type ImportError struct {
+	Row   int    `json:"row"`
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error"`
+}
+
+// FormatAmount renders minor units as a decimal string in major units, the inverse of
+// ParseAmount.
+func FormatAmount(cents int64) string {
+	sign := ""
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// ProductFromCSV builds a ProductInput from a CSV record. columns maps column names
+// from the file's header to their index; unknown columns are ignored and missing
+// ones are left empty.
+func ProductFromCSV(columns map[string]int, record []string) (ProductInput, error) {
+	field := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	in := ProductInput{
+		ID:          field("id"),
+		Name:        field("name"),
+		Description: field("description"),
+		ImageURL:    field("image_url"),
+		Category:    field("category"),
+		Price:       money.Money{Currency: field("currency")},
+		Status:      field("status"),
+	}
+	amount, err := money.ParseAmount(field("price"))
+	if err != nil {
+		return in, validationError("price must be a decimal amount such as 19.99")
+	}
+	in.Price.Amount = amount
+	if attributes := field("attributes"); attributes != "" {
+		if err := json.Unmarshal([]byte(attributes), &in.Attributes); err != nil {
+			return in, validationError("attributes must be a JSON object")
+		}
+	}
+	for name, dst := range map[string]**time.Time{"publish_at": &in.PublishAt, "unpublish_at": &in.UnpublishAt} {
+		if v := field(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return in, validationError("%s must be an RFC 3339 timestamp", name)
+			}
+			*dst = &t
+		}
+	}
+	return in, nil
+}
+
+// CSVRecord is the inverse of ProductFromCSV for the CSVColumns layout. The list price
+// is exported so scheduled prices do not leak into the product on re-import.
+func (p Product) CSVRecord() []string {
+	attributes := "{}"
+	if len(p.Attributes) > 0 {
+		data, _ := json.Marshal(p.Attributes)
+		attributes = string(data)
+	}
+	return []string{
+		p.ID, p.Name, p.Description, FormatAmount(p.ListPrice.Amount), p.ListPrice.Currency, p.ImageURL, p.Category, attributes,
+		p.Status, formatOptionalTime(p.PublishAt), formatOptionalTime(p.UnpublishAt),
+	}
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
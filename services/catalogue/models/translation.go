@@ -0,0 +1,110 @@
+package models
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultLocale is the language of the name and description stored on the product
+// itself. Other locales are served from translations and fall back to it.
+const DefaultLocale = "en"
+
+// MaxLocales caps how many Accept-Language candidates are matched against translations.
+const MaxLocales = 10
+
+var localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+// NormalizeLocale lower-cases a BCP 47 language tag and accepts underscores as
+// separators, so "pt_BR" and "pt-BR" both become "pt-br".
+func NormalizeLocale(locale string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(locale)), "_", "-")
+}
+
+// ValidLocale reports whether locale is a normalized language tag such as "de" or "pt-br".
+func ValidLocale(locale string) bool {
+	return len(locale) <= 35 && localePattern.MatchString(locale)
+}
+
+// LocaleCandidates turns an Accept-Language value (or a single locale) into the
+// translations to try, most preferred first. Each region tag is followed by its base
+// language ("de-ch" then "de"). The list stops at DefaultLocale, since the product's
+// own content already is that language; nil means no translation is wanted.
+// Malformed and wildcard entries are skipped.
+func LocaleCandidates(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = NormalizeLocale(tag)
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q <= 0 || !ValidLocale(tag) {
+			continue
+		}
+		tags = append(tags, weighted{tag, q})
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	var candidates []string
+	seen := map[string]bool{}
+	for _, t := range tags {
+		base, _, _ := strings.Cut(t.tag, "-")
+		for _, locale := range []string{t.tag, base} {
+			if locale == DefaultLocale {
+				return candidates
+			}
+			if !seen[locale] && len(candidates) < MaxLocales {
+				seen[locale] = true
+				candidates = append(candidates, locale)
+			}
+		}
+	}
+	return candidates
+}
+
+// Translation is a product's name and description in one locale.
+type Translation struct {
+	Locale      string    `json:"locale"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TranslationInput is the body accepted by PUT /api/products/{id}/translations/{locale}.
+type TranslationInput struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+func (in *TranslationInput) Normalize() {
+	in.Name = strings.TrimSpace(in.Name)
+	in.Description = strings.TrimSpace(in.Description)
+}
+
+func (in TranslationInput) Validate(locale string) error {
+	if !ValidLocale(locale) {
+		return validationError("locale must be a language tag such as de or pt-BR")
+	}
+	if locale == DefaultLocale {
+		return validationError("%s is the default locale; edit the product itself", DefaultLocale)
+	}
+	if in.Name == "" {
+		return validationError("name is required")
+	}
+	if len(in.Name) > 255 {
+		return validationError("name must be at most 255 characters")
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/metalbear-co/metalmart/pkg/money"
+)
+
+// Variant is a purchasable SKU of a product, e.g. a size/colour combination. Price is
+// the effective price: the product price, or PriceOverride when set, reduced in
+// proportion to the product's scheduled price while one is in effect. CompareAt is
+// then the price before the reduction. Overrides are always in the product's currency,
+// which cannot change while any variant has one.
+type Variant struct {
+	ID            string            `json:"id"`
+	ProductID     string            `json:"product_id"`
+	SKU           string            `json:"sku"`
+	Attributes    map[string]string `json:"attributes"`
+	PriceOverride *money.Money      `json:"price_override,omitempty"`
+	Price         money.Money       `json:"price"`
+	CompareAt     *money.Money      `json:"compare_at,omitempty"`
+	ImageURL      string            `json:"image_url,omitempty"`
+	Position      int               `json:"position"`
+	CreatedAt     time.Time         `json:"created_at"`
+}
+
+// VariantInput is the body accepted by POST /api/products/{id}/variants and
+// PUT /api/products/{id}/variants/{variantId}.
+type VariantInput struct {
+	ID            string            `json:"id,omitempty"`
+	SKU           string            `json:"sku"`
+	Attributes    map[string]string `json:"attributes"`
+	PriceOverride *money.Money      `json:"price_override"`
+	ImageURL      string            `json:"image_url"`
+	Position      int               `json:"position"`
+}
+
+func (in *VariantInput) Normalize() {
+	in.ID = strings.TrimSpace(in.ID)
+	in.SKU = strings.TrimSpace(in.SKU)
+	in.ImageURL = strings.TrimSpace(in.ImageURL)
+	if in.PriceOverride != nil {
+		in.PriceOverride.Currency = strings.ToUpper(strings.TrimSpace(in.PriceOverride.Currency))
+	}
+}
+
+func (in VariantInput) Validate() error {
+	if len(in.ID) > 50 {
+		return validationError("id must be at most 50 characters")
+	}
+	if strings.ContainsAny(in.ID, "/?#") {
+		return validationError("id must not contain '/', '?' or '#'")
+	}
+	if in.SKU == "" {
+		return validationError("sku is required")
+	}
+	if len(in.SKU) > 64 {
+		return validationError("sku must be at most 64 characters")
+	}
+	if len(in.Attributes) == 0 {
+		return validationError("attributes are required, e.g. {\"size\": \"M\"}")
+	}
+	for key, value := range in.Attributes {
+		if key == "" || len(key) > 64 {
+			return validationError("attribute names must be 1 to 64 characters")
+		}
+		if value == "" {
+			return validationError("attribute %s must have a value", key)
+		}
+	}
+	if in.PriceOverride != nil && in.PriceOverride.Amount <= 0 {
+		return validationError("price_override.amount must be greater than zero")
+	}
+	if len(in.ImageURL) > 500 {
+		return validationError("image_url must be at most 500 characters")
+	}
+	return nil
+}
@@ -2,14 +2,22 @@
 
 # Seed inventory for all products in the catalogue
 
-# Get all products from the catalogue service
-PRODUCTS=$(curl -s http://catalogue:8081/api/products)
+# Get all products from the catalogue service, following next_cursor until the last page
+PRODUCTS=""
+CURSOR=""
+while : ; do
+  PAGE=$(curl -s -G http://catalogue:8081/api/products --data-urlencode "limit=200" --data-urlencode "cursor=$CURSOR")
+  PRODUCTS="$PRODUCTS$(echo "$PAGE" | jq -c '.products[]')
+"
+  CURSOR=$(echo "$PAGE" | jq -r '.next_cursor // empty')
+  [ -z "$CURSOR" ] && break
+done
 
 # Log the products to the console
 echo "Products: $PRODUCTS"
 
 # Iterate over each product and seed inventory
-echo "$PRODUCTS" | jq -c '.[]' | while read -r product; do
+echo "$PRODUCTS" | grep -v '^$' | while read -r product; do
   echo "Processing product: $product"
   PRODUCT_ID=$(echo "$product" | jq -r '.id')
   echo "Extracted PRODUCT_ID: $PRODUCT_ID"
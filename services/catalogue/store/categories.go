@@ -0,0 +1,220 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/metalbear-co/metalmart/services/catalogue/models"
+)
+
+var (
+	ErrCategoryNotFound = errors.New("category not found")
+	ErrCategoryInUse    = errors.New("category still has subcategories or products")
+)
+
+// migrateCategories creates the category tree and turns every category already used
+// by a product into a root category before products.category becomes a foreign key.
+// The seed categories are then arranged under their parents once; roots the first
+// step generated are moved and renamed, but categories an admin has placed or
+// renamed are left alone.
+func (s *PostgresStore) migrateCategories() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS categories (
+		slug VARCHAR(100) PRIMARY KEY,
+		parent_slug VARCHAR(100) REFERENCES categories(slug) ON UPDATE CASCADE,
+		name VARCHAR(255) NOT NULL,
+		sort_order INT NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_categories_parent_slug ON categories(parent_slug);
+
+	UPDATE products SET category = NULL WHERE category = '';
+	INSERT INTO categories (slug, name)
+	SELECT DISTINCT category, initcap(replace(category, '-', ' ')) FROM products WHERE category IS NOT NULL
+	ON CONFLICT (slug) DO NOTHING;
+
+	DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'products_category_fkey') THEN
+			ALTER TABLE products ADD CONSTRAINT products_category_fkey
+				FOREIGN KEY (category) REFERENCES categories(slug) ON UPDATE CASCADE;
+		END IF;
+	END $$;
+	`
+	if _, err := s.db.Exec(query); err != nil {
+		return err
+	}
+
+	return s.migrateOnce("seed categories", `
+		INSERT INTO categories (slug, parent_slug, name, sort_order) VALUES
+			('apparel', NULL, 'Apparel', 1),
+			('t-shirts', 'apparel', 'T-Shirts', 1),
+			('hoodies', 'apparel', 'Hoodies', 2),
+			('accessories', NULL, 'Accessories', 2)
+		ON CONFLICT (slug) DO UPDATE SET
+			parent_slug = EXCLUDED.parent_slug,
+			name = CASE WHEN categories.name = initcap(replace(categories.slug, '-', ' ')) THEN EXCLUDED.name ELSE categories.name END,
+			sort_order = EXCLUDED.sort_order
+		WHERE categories.parent_slug IS NULL AND categories.sort_order = 0
+	`)
+}
+
+// categorySubtree returns a subquery selecting the slugs of the categories matching
+// root (a condition on slug) and of all their descendants.
+func categorySubtree(root string) string {
+	return `(WITH RECURSIVE subtree AS (
+		SELECT slug FROM categories WHERE ` + root + `
+		UNION ALL
+		SELECT c.slug FROM categories c JOIN subtree t ON c.parent_slug = t.slug
+	) SELECT slug FROM subtree)`
+}
+
+// ListCategories returns the category tree. Siblings are ordered by sort_order, then name.
+func (s *PostgresStore) ListCategories() ([]models.Category, error) {
+	rows, err := s.db.Query(`
+		SELECT slug, COALESCE(parent_slug, ''), name, sort_order, created_at
+		FROM categories
+		ORDER BY sort_order, name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var flat []models.Category
+	for rows.Next() {
+		var c models.Category
+		if err := rows.Scan(&c.Slug, &c.ParentSlug, &c.Name, &c.SortOrder, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		flat = append(flat, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return buildCategoryTree(flat, ""), nil
+}
+
+func buildCategoryTree(flat []models.Category, parent string) []models.Category {
+	nodes := []models.Category{}
+	for _, c := range flat {
+		if c.ParentSlug == parent {
+			c.Children = buildCategoryTree(flat, c.Slug)
+			nodes = append(nodes, c)
+		}
+	}
+	return nodes
+}
+
+func (s *PostgresStore) GetCategory(slug string) (*models.Category, error) {
+	var c models.Category
+	err := s.db.QueryRow(`
+		SELECT slug, COALESCE(parent_slug, ''), name, sort_order, created_at FROM categories WHERE slug = $1
+	`, slug).Scan(&c.Slug, &c.ParentSlug, &c.Name, &c.SortOrder, &c.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCategoryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.Children = []models.Category{}
+	return &c, nil
+}
+
+func (s *PostgresStore) CreateCategory(in models.CategoryInput) (*models.Category, error) {
+	_, err := s.db.Exec(`
+		INSERT INTO categories (slug, parent_slug, name, sort_order) VALUES ($1, NULLIF($2, ''), $3, $4)
+	`, in.Slug, in.ParentSlug, in.Name, in.SortOrder)
+	if err != nil {
+		return nil, categoryWriteError(err)
+	}
+	return s.GetCategory(in.Slug)
+}
+
+// UpdateCategory renames, re-parents or re-orders a category. Products and rankings
+// follow a slug change; moving a category under one of its own descendants is rejected.
+func (s *PostgresStore) UpdateCategory(slug string, in models.CategoryInput) (*models.Category, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if in.ParentSlug != "" {
+		var cycle bool
+		err := tx.QueryRow(`SELECT $2::text IN `+categorySubtree("slug = $1::text"), slug, in.ParentSlug).Scan(&cycle)
+		if err != nil {
+			return nil, err
+		}
+		if cycle {
+			return nil, fmt.Errorf("%w: %s is a subcategory of %s", models.ErrValidation, in.ParentSlug, slug)
+		}
+	}
+
+	res, err := tx.Exec(`
+		UPDATE categories SET slug = $2, parent_slug = NULLIF($3, ''), name = $4, sort_order = $5 WHERE slug = $1
+	`, slug, in.Slug, in.ParentSlug, in.Name, in.SortOrder)
+	if err != nil {
+		return nil, categoryWriteError(err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrCategoryNotFound
+	}
+	if in.Slug != slug {
+		if _, err := tx.Exec(`UPDATE category_rankings SET category = $2 WHERE category = $1`, slug, in.Slug); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.GetCategory(in.Slug)
+}
+
+// DeleteCategory removes a category that has no subcategories and no products.
+func (s *PostgresStore) DeleteCategory(slug string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM category_rankings WHERE category = $1`, slug); err != nil {
+		return err
+	}
+	res, err := tx.Exec(`DELETE FROM categories WHERE slug = $1`, slug)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return ErrCategoryInUse
+	}
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrCategoryNotFound
+	}
+	return tx.Commit()
+}
+
+// categoryWriteError maps a duplicate slug to ErrDuplicateID and a missing parent to a
+// validation error.
+func categoryWriteError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+	switch pqErr.Code {
+	case "23505":
+		return fmt.Errorf("%w: category already exists", ErrDuplicateID)
+	case "23503":
+		return fmt.Errorf("%w: unknown parent category", models.ErrValidation)
+	}
+	return err
+}
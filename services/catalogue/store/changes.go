@@ -0,0 +1,67 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// changeTrackedTables are the tables catalogue GET responses are built from.
+var changeTrackedTables = []string{
+	"products", "product_variants", "product_media", "product_prices", "product_price_lists",
+	"product_translations", "product_reviews", "product_copurchases",
+	"categories", "category_rankings", "currency_rates",
+}
+
+// migrateChanges records when each table served by the catalogue last changed. A
+// statement trigger bumps the table's row on every insert, update and delete, so
+// deletes, reorders and rate changes move it as well as product edits. It runs after
+// every table has been created.
+func (s *PostgresStore) migrateChanges() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS catalogue_changes (
+		table_name VARCHAR(63) PRIMARY KEY,
+		changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+
+	CREATE OR REPLACE FUNCTION record_catalogue_change() RETURNS trigger AS $$
+	BEGIN
+		INSERT INTO catalogue_changes (table_name, changed_at) VALUES (TG_TABLE_NAME, clock_timestamp())
+		ON CONFLICT (table_name) DO UPDATE SET changed_at = GREATEST(catalogue_changes.changed_at, clock_timestamp());
+		RETURN NULL;
+	END;
+	$$ LANGUAGE plpgsql;
+	`
+	for _, table := range changeTrackedTables {
+		query += `
+		INSERT INTO catalogue_changes (table_name) VALUES ('` + table + `') ON CONFLICT (table_name) DO NOTHING;
+		DROP TRIGGER IF EXISTS record_catalogue_change ON ` + table + `;
+		CREATE TRIGGER record_catalogue_change AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON ` + table + `
+			FOR EACH STATEMENT EXECUTE FUNCTION record_catalogue_change();
+		`
+	}
+	_, err := s.db.Exec(query)
+	return err
+}
+
+// ChangeTimes returns when the data served by the catalogue last changed and when it
+// next changes by time alone. The last change is the latest write to any of its
+// tables, or a publishing window or scheduled price starting or ending since. The next
+// is the earliest such boundary still ahead. Either is zero when there is none.
+func (s *PostgresStore) ChangeTimes() (last, next time.Time, err error) {
+	var l, n sql.NullTime
+	err = s.db.QueryRow(`
+		SELECT GREATEST(
+			(SELECT MAX(changed_at) FROM catalogue_changes),
+			(SELECT MAX(publish_at) FROM products WHERE publish_at <= NOW()),
+			(SELECT MAX(unpublish_at) FROM products WHERE unpublish_at <= NOW()),
+			(SELECT MAX(effective_from) FROM product_prices WHERE kind = 'scheduled' AND effective_from <= NOW()),
+			(SELECT MAX(effective_to) FROM product_prices WHERE kind = 'scheduled' AND effective_to <= NOW())
+		), LEAST(
+			(SELECT MIN(publish_at) FROM products WHERE publish_at > NOW()),
+			(SELECT MIN(unpublish_at) FROM products WHERE unpublish_at > NOW()),
+			(SELECT MIN(effective_from) FROM product_prices WHERE kind = 'scheduled' AND effective_from > NOW()),
+			(SELECT MIN(effective_to) FROM product_prices WHERE kind = 'scheduled' AND effective_to > NOW())
+		)
+	`).Scan(&l, &n)
+	return l.Time, n.Time, err
+}
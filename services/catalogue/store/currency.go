@@ -0,0 +1,282 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/lib/pq"
+	"github.com/metalbear-co/metalmart/pkg/money"
+	"github.com/metalbear-co/metalmart/services/catalogue/models"
+)
+
+func (s *PostgresStore) migrateCurrencies() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS currency_rates (
+		currency CHAR(3) PRIMARY KEY,
+		rate NUMERIC(18,8) NOT NULL CHECK (rate > 0),
+		rounding VARCHAR(10) NOT NULL DEFAULT 'nearest',
+		increment BIGINT NOT NULL DEFAULT 1 CHECK (increment > 0),
+		updated_at TIMESTAMP DEFAULT NOW()
+	);
+
+	CREATE TABLE IF NOT EXISTS product_price_lists (
+		product_id VARCHAR(50) NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+		currency CHAR(3) NOT NULL,
+		price_cents BIGINT NOT NULL CHECK (price_cents >= 0),
+		PRIMARY KEY (product_id, currency)
+	);
+	`
+	if _, err := s.db.Exec(query); err != nil {
+		return err
+	}
+
+	return s.migrateOnce("seed currency rates", `
+		INSERT INTO currency_rates (currency, rate, rounding, increment) VALUES
+			('EUR', 0.92, 'nearest', 1),
+			('GBP', 0.79, 'nearest', 1)
+		ON CONFLICT (currency) DO NOTHING
+	`)
+}
+
+func (s *PostgresStore) ListCurrencyRates() ([]models.CurrencyRate, error) {
+	rows, err := s.db.Query(`SELECT currency, rate::text, rounding, increment, updated_at FROM currency_rates ORDER BY currency`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := []models.CurrencyRate{}
+	for rows.Next() {
+		var r models.CurrencyRate
+		if err := rows.Scan(&r.Currency, &r.Rate, &r.Rounding, &r.Increment, &r.UpdatedAt); err != nil {
+			return nil, err
+		}
+		rates = append(rates, r)
+	}
+	return rates, rows.Err()
+}
+
+func (s *PostgresStore) SetCurrencyRate(r models.CurrencyRate) (*models.CurrencyRate, error) {
+	err := s.db.QueryRow(`
+		INSERT INTO currency_rates (currency, rate, rounding, increment) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (currency) DO UPDATE SET
+			rate = EXCLUDED.rate, rounding = EXCLUDED.rounding, increment = EXCLUDED.increment, updated_at = NOW()
+		RETURNING rate::text, updated_at
+	`, r.Currency, r.Rate.String(), r.Rounding, r.Increment).Scan(&r.Rate, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (s *PostgresStore) DeleteCurrencyRate(currency string) error {
+	res, err := s.db.Exec(`DELETE FROM currency_rates WHERE currency = $1`, currency)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetPriceList returns the fixed prices of a product, one per currency.
+func (s *PostgresStore) GetPriceList(productID string) ([]money.Money, error) {
+	if _, err := s.GetProduct(productID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`SELECT price_cents, currency FROM product_price_lists WHERE product_id = $1 ORDER BY currency`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prices := []money.Money{}
+	for rows.Next() {
+		var m money.Money
+		if err := rows.Scan(&m.Amount, &m.Currency); err != nil {
+			return nil, err
+		}
+		prices = append(prices, m)
+	}
+	return prices, rows.Err()
+}
+
+func (s *PostgresStore) SetListPrice(productID string, price money.Money) error {
+	_, err := s.db.Exec(`
+		INSERT INTO product_price_lists (product_id, currency, price_cents) VALUES ($1, $2, $3)
+		ON CONFLICT (product_id, currency) DO UPDATE SET price_cents = EXCLUDED.price_cents
+	`, productID, price.Currency, price.Amount)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return s.touchProduct(productID)
+}
+
+func (s *PostgresStore) DeleteListPrice(productID, currency string) error {
+	res, err := s.db.Exec(`DELETE FROM product_price_lists WHERE product_id = $1 AND currency = $2`, productID, currency)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return s.touchProduct(productID)
+}
+
+// Localize rewrites the prices of products (and their variants) into currency. A
+// product's fixed price list entry wins unless a scheduled price is in effect, and is
+// then the compare_at of a sale marked down from the list price; otherwise prices are
+// converted with the currency rates and rounded by the target currency's rule. Variant
+// overrides are always converted. All supported currencies are assumed to have
+// two-decimal minor units.
+func (s *PostgresStore) Localize(currency string, products ...*models.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+	c, err := s.converter(currency)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]string, len(products))
+	for i, p := range products {
+		ids[i] = p.ID
+	}
+	listed := map[string]int64{}
+	rows, err := s.db.Query(`SELECT product_id, price_cents FROM product_price_lists WHERE currency = $1 AND product_id = ANY($2)`, currency, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var cents int64
+		if err := rows.Scan(&id, &cents); err != nil {
+			return err
+		}
+		listed[id] = cents
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, p := range products {
+		cents, ok := listed[p.ID]
+		listPrice := money.Money{Amount: cents, Currency: currency}
+		if ok && p.Price == p.ListPrice {
+			p.Price = listPrice
+		} else if p.Price, err = c.convert(p.Price); err != nil {
+			return err
+		}
+		if p.CompareAt != nil {
+			compareAt := listPrice
+			if !ok || *p.CompareAt != p.ListPrice {
+				if compareAt, err = c.convert(*p.CompareAt); err != nil {
+					return err
+				}
+			}
+			p.CompareAt = &compareAt
+			if compareAt.Amount <= p.Price.Amount {
+				p.CompareAt = nil
+			}
+		}
+		if err := c.localizeVariants(p.Variants, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// converter holds the rates (units per DefaultCurrency) needed to convert into target.
+type converter struct {
+	target models.CurrencyRate
+	rates  map[string]*big.Rat
+}
+
+func (s *PostgresStore) converter(currency string) (*converter, error) {
+	c := &converter{
+		target: models.CurrencyRate{Currency: currency, Rounding: models.RoundNearest, Increment: 1},
+		rates:  map[string]*big.Rat{money.DefaultCurrency: big.NewRat(1, 1)},
+	}
+	rates, err := s.ListCurrencyRates()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rates {
+		rate, ok := new(big.Rat).SetString(r.Rate.String())
+		if !ok {
+			return nil, fmt.Errorf("invalid rate %q for %s", r.Rate, r.Currency)
+		}
+		c.rates[r.Currency] = rate
+		if r.Currency == currency {
+			c.target = r
+		}
+	}
+	if c.rates[currency] == nil {
+		return nil, fmt.Errorf("%w: unsupported currency %s", models.ErrValidation, currency)
+	}
+	return c, nil
+}
+
+func (c *converter) convert(m money.Money) (money.Money, error) {
+	if m.Currency == c.target.Currency {
+		return m, nil
+	}
+	from, ok := c.rates[m.Currency]
+	if !ok {
+		return m, fmt.Errorf("no rate for %s", m.Currency)
+	}
+	// amount * to/from, expressed in units of the rounding increment
+	v := new(big.Rat).SetInt64(m.Amount)
+	v.Mul(v, c.rates[c.target.Currency])
+	v.Quo(v, from)
+	v.Quo(v, big.NewRat(c.target.Increment, 1))
+
+	q, r := new(big.Int).QuoRem(v.Num(), v.Denom(), new(big.Int))
+	switch c.target.Rounding {
+	case models.RoundUp:
+		if r.Sign() > 0 {
+			q.Add(q, big.NewInt(1))
+		}
+	case models.RoundNearest:
+		if new(big.Int).Mul(r, big.NewInt(2)).Cmp(v.Denom()) >= 0 {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return money.Money{Amount: q.Int64() * c.target.Increment, Currency: c.target.Currency}, nil
+}
+
+// localizeVariants rewrites variant prices into the target currency. Variants without
+// an override take the localized price and compare_at of p.
+func (c *converter) localizeVariants(variants []models.Variant, p *models.Product) error {
+	for i := range variants {
+		v := &variants[i]
+		if v.PriceOverride == nil {
+			v.Price, v.CompareAt = p.Price, p.CompareAt
+			continue
+		}
+		override, err := c.convert(*v.PriceOverride)
+		if err != nil {
+			return err
+		}
+		v.PriceOverride = &override
+		if v.Price, err = c.convert(v.Price); err != nil {
+			return err
+		}
+		if v.CompareAt != nil {
+			compareAt, err := c.convert(*v.CompareAt)
+			if err != nil {
+				return err
+			}
+			v.CompareAt = &compareAt
+		}
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+package store
+
+// migrateLifecycle adds the product status and publishing window. Existing products
+// were always live, so they default to published.
+func (s *PostgresStore) migrateLifecycle() error {
+	query := `
+	ALTER TABLE products ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'published';
+	ALTER TABLE products ADD COLUMN IF NOT EXISTS publish_at TIMESTAMPTZ;
+	ALTER TABLE products ADD COLUMN IF NOT EXISTS unpublish_at TIMESTAMPTZ;
+	CREATE INDEX IF NOT EXISTS idx_products_status ON products(status);
+	`
+	_, err := s.db.Exec(query)
+	return err
+}
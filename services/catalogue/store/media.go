@@ -0,0 +1,258 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/metalbear-co/metalmart/services/catalogue/models"
+)
+
+// ErrMediaNotFound is returned when a product has no media with the requested id.
+var ErrMediaNotFound = errors.New("media not found")
+
+// migrateMedia creates the product image gallery and moves every existing image_url
+// into it as the product's primary image. products.image_url stays as a copy of the
+// primary image's url so listings need no join.
+func (s *PostgresStore) migrateMedia() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS product_media (
+		id BIGSERIAL PRIMARY KEY,
+		product_id VARCHAR(50) NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+		url VARCHAR(500) NOT NULL,
+		alt_text VARCHAR(500) NOT NULL DEFAULT '',
+		width INT CHECK (width > 0),
+		height INT CHECK (height > 0),
+		role VARCHAR(20) NOT NULL DEFAULT '',
+		position INT NOT NULL DEFAULT 0,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_product_media_product_id ON product_media(product_id, position);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_product_media_primary ON product_media(product_id) WHERE role = 'primary';
+
+	INSERT INTO product_media (product_id, url, alt_text, role, position)
+	SELECT p.id, p.image_url, p.name, 'primary', 0 FROM products p
+	WHERE COALESCE(p.image_url, '') <> ''
+		AND NOT EXISTS (SELECT 1 FROM product_media m WHERE m.product_id = p.id);
+	`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+const mediaColumns = `id, product_id, url, alt_text, width, height, role, position, created_at`
+
+func scanMedia(row rowScanner, m *models.Media) error {
+	var width, height sql.NullInt64
+	err := row.Scan(&m.ID, &m.ProductID, &m.URL, &m.AltText, &width, &height, &m.Role, &m.Position, &m.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if width.Valid {
+		w := int(width.Int64)
+		m.Width = &w
+	}
+	if height.Valid {
+		h := int(height.Int64)
+		m.Height = &h
+	}
+	return nil
+}
+
+// ListMedia returns the gallery of a product in display order.
+func (s *PostgresStore) ListMedia(productID string) ([]models.Media, error) {
+	rows, err := s.db.Query(`SELECT `+mediaColumns+` FROM product_media WHERE product_id = $1 ORDER BY position, id`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	media := []models.Media{}
+	for rows.Next() {
+		var m models.Media
+		if err := scanMedia(rows, &m); err != nil {
+			return nil, err
+		}
+		media = append(media, m)
+	}
+	return media, rows.Err()
+}
+
+// AddMedia attaches an image to a product. A new primary image demotes the previous
+// one to the gallery.
+func (s *PostgresStore) AddMedia(productID string, in models.MediaInput) (*models.Media, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := lockProduct(tx, productID); err != nil {
+		return nil, err
+	}
+	if in.Role == models.MediaPrimary {
+		if _, err := tx.Exec(`UPDATE product_media SET role = '' WHERE product_id = $1 AND role = 'primary'`, productID); err != nil {
+			return nil, err
+		}
+	}
+
+	var m models.Media
+	err = scanMedia(tx.QueryRow(`
+		INSERT INTO product_media (product_id, url, alt_text, width, height, role, position)
+		VALUES ($1, $2, $3, $4, $5, $6, COALESCE($7, (SELECT COALESCE(MAX(position) + 1, 0) FROM product_media WHERE product_id = $1)))
+		RETURNING `+mediaColumns+`
+	`, productID, in.URL, in.AltText, in.Width, in.Height, in.Role, in.Position), &m)
+	if err != nil {
+		return nil, err
+	}
+	if err := syncImageURL(tx, productID); err != nil {
+		return nil, err
+	}
+	return &m, tx.Commit()
+}
+
+// UpdateMedia replaces an image's metadata. A nil position keeps the current one.
+func (s *PostgresStore) UpdateMedia(productID string, mediaID int64, in models.MediaInput) (*models.Media, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := lockProduct(tx, productID); err != nil {
+		return nil, err
+	}
+	if in.Role == models.MediaPrimary {
+		if _, err := tx.Exec(`UPDATE product_media SET role = '' WHERE product_id = $1 AND role = 'primary' AND id <> $2`, productID, mediaID); err != nil {
+			return nil, err
+		}
+	}
+
+	var m models.Media
+	err = scanMedia(tx.QueryRow(`
+		UPDATE product_media
+		SET url = $3, alt_text = $4, width = $5, height = $6, role = $7, position = COALESCE($8, position)
+		WHERE product_id = $1 AND id = $2
+		RETURNING `+mediaColumns+`
+	`, productID, mediaID, in.URL, in.AltText, in.Width, in.Height, in.Role, in.Position), &m)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrMediaNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := syncImageURL(tx, productID); err != nil {
+		return nil, err
+	}
+	return &m, tx.Commit()
+}
+
+// DeleteMedia removes an image from a product. Deleting the primary image clears the
+// product's image_url.
+func (s *PostgresStore) DeleteMedia(productID string, mediaID int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`DELETE FROM product_media WHERE product_id = $1 AND id = $2`, productID, mediaID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrMediaNotFound
+	}
+	if err := syncImageURL(tx, productID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReorderMedia moves the listed media to the front of the gallery in the given order
+// and renumbers the rest after them.
+func (s *PostgresStore) ReorderMedia(productID string, ids []int64) ([]models.Media, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := lockProduct(tx, productID); err != nil {
+		return nil, err
+	}
+	var found int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM product_media WHERE product_id = $1 AND id = ANY($2)`, productID, pq.Array(ids)).Scan(&found); err != nil {
+		return nil, err
+	}
+	if found != len(ids) {
+		return nil, fmt.Errorf("%w: media_ids must only list media of this product", models.ErrValidation)
+	}
+
+	_, err = tx.Exec(`
+		WITH ordered AS (
+			SELECT m.id, ROW_NUMBER() OVER (ORDER BY o.ord NULLS LAST, m.position, m.id) - 1 AS rn
+			FROM product_media m
+			LEFT JOIN unnest($2::bigint[]) WITH ORDINALITY AS o(id, ord) ON o.id = m.id
+			WHERE m.product_id = $1
+		)
+		UPDATE product_media m SET position = ordered.rn
+		FROM ordered
+		WHERE m.id = ordered.id
+	`, productID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`UPDATE products SET updated_at = NOW() WHERE id = $1`, productID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.ListMedia(productID)
+}
+
+// lockProduct locks the product row so concurrent gallery writes serialize, returning
+// ErrNotFound when the product does not exist.
+func lockProduct(tx *sql.Tx, productID string) error {
+	var id string
+	err := tx.QueryRow(`SELECT id FROM products WHERE id = $1 FOR UPDATE`, productID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// syncImageURL copies the url of the product's primary image to products.image_url
+// after a gallery change.
+func syncImageURL(db execer, productID string) error {
+	_, err := db.Exec(`
+		UPDATE products SET
+			image_url = COALESCE((SELECT url FROM product_media WHERE product_id = $1 AND role = 'primary'), ''),
+			updated_at = NOW()
+		WHERE id = $1
+	`, productID)
+	return err
+}
+
+// syncPrimaryMedia makes the product's primary image follow products.image_url after a
+// product write: a new url replaces the primary image (whose dimensions no longer
+// apply), and an empty one removes it. All statements see the same snapshot.
+func syncPrimaryMedia(db execer, productID string) error {
+	_, err := db.Exec(`
+		WITH p AS (
+			SELECT id, name, COALESCE(image_url, '') AS url FROM products WHERE id = $1
+		), removed AS (
+			DELETE FROM product_media m USING p
+			WHERE m.product_id = p.id AND m.role = 'primary' AND p.url = ''
+		), changed AS (
+			UPDATE product_media m SET url = p.url, width = NULL, height = NULL
+			FROM p
+			WHERE m.product_id = p.id AND m.role = 'primary' AND p.url <> '' AND m.url <> p.url
+		)
+		INSERT INTO product_media (product_id, url, alt_text, role, position)
+		SELECT p.id, p.url, p.name, 'primary', 0 FROM p
+		WHERE p.url <> '' AND NOT EXISTS (SELECT 1 FROM product_media m WHERE m.product_id = p.id AND m.role = 'primary')
+	`, productID)
+	return err
+}
@@ -0,0 +1,212 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/metalbear-co/metalmart/services/catalogue/models"
+)
+
+func (s *PostgresStore) migrateMerchandising() error {
+	query := `
+	ALTER TABLE products ADD COLUMN IF NOT EXISTS featured_rank INT;
+	CREATE INDEX IF NOT EXISTS idx_products_featured_rank ON products(featured_rank);
+
+	CREATE TABLE IF NOT EXISTS category_rankings (
+		category VARCHAR(100) NOT NULL,
+		product_id VARCHAR(50) NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+		position INT NOT NULL,
+		PRIMARY KEY (category, product_id)
+	);
+	`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+func (s *PostgresStore) GetMerchandising() (*models.Merchandising, error) {
+	m := models.Merchandising{
+		Order:            []string{},
+		Featured:         []string{},
+		CategoryRankings: map[string][]string{},
+	}
+
+	if err := queryIDs(s.db, &m.Order, `SELECT id FROM products ORDER BY COALESCE(display_order, 99), id`); err != nil {
+		return nil, err
+	}
+	if err := queryIDs(s.db, &m.Featured, `SELECT id FROM products WHERE featured_rank IS NOT NULL ORDER BY featured_rank`); err != nil {
+		return nil, err
+	}
+
+	rankRows, err := s.db.Query(`SELECT category, product_id FROM category_rankings ORDER BY category, position`)
+	if err != nil {
+		return nil, err
+	}
+	defer rankRows.Close()
+
+	for rankRows.Next() {
+		var category, id string
+		if err := rankRows.Scan(&category, &id); err != nil {
+			return nil, err
+		}
+		m.CategoryRankings[category] = append(m.CategoryRankings[category], id)
+	}
+	return &m, rankRows.Err()
+}
+
+func queryIDs(db *sql.DB, dst *[]string, query string) error {
+	rows, err := db.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		*dst = append(*dst, id)
+	}
+	return rows.Err()
+}
+
+// ReorderProducts sets the global display order. Listed products come first in the given
+// order; the rest keep their relative order behind them.
+func (s *PostgresStore) ReorderProducts(ids []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkProductsExist(tx, ids, ""); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE products p SET display_order = o.ord
+		FROM unnest($1::text[]) WITH ORDINALITY AS o(id, ord)
+		WHERE p.id = o.id
+	`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		WITH rest AS (
+			SELECT id, ROW_NUMBER() OVER (ORDER BY COALESCE(display_order, 99), id) AS rn
+			FROM products
+			WHERE NOT (id = ANY($1))
+		)
+		UPDATE products p SET display_order = $2 + rest.rn
+		FROM rest
+		WHERE p.id = rest.id
+	`, pq.Array(ids), len(ids))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetFeatured replaces the pinned products. They are listed ahead of the display order.
+func (s *PostgresStore) SetFeatured(ids []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkProductsExist(tx, ids, ""); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE products SET featured_rank = NULL WHERE featured_rank IS NOT NULL`); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE products p SET featured_rank = o.ord
+		FROM unnest($1::text[]) WITH ORDINALITY AS o(id, ord)
+		WHERE p.id = o.id
+	`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RankCategory replaces the ranking within a category. Ranked products are listed first
+// by ListByCategory; an empty list clears the ranking.
+func (s *PostgresStore) RankCategory(category string, ids []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkProductsExist(tx, ids, category); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM category_rankings WHERE category = $1`, category); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO category_rankings (category, product_id, position)
+		SELECT $1, o.id, o.ord
+		FROM unnest($2::text[]) WITH ORDINALITY AS o(id, ord)
+	`, category, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// checkProductsExist returns a validation error naming any ids that are not products
+// (or, when category is set, not products in that category or its subcategories).
+func checkProductsExist(tx *sql.Tx, ids []string, category string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	rows, err := tx.Query(`
+		SELECT id FROM products
+		WHERE id = ANY($1) AND ($2::text = '' OR category IN `+categorySubtree("slug = $2::text")+`)
+	`, pq.Array(ids), category)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	found := make(map[string]bool, len(ids))
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		found[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, id := range ids {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	if category != "" {
+		return fmt.Errorf("%w: products not in category %s: %s", models.ErrValidation, category, strings.Join(missing, ", "))
+	}
+	return fmt.Errorf("%w: unknown products: %s", models.ErrValidation, strings.Join(missing, ", "))
+}
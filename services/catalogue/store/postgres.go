@@ -2,12 +2,21 @@ package store
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 
-	_ "github.com/lib/pq"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/metalbear-co/metalmart/pkg/money"
 	"github.com/metalbear-co/metalmart/services/catalogue/models"
 )
 
+var (
+	ErrNotFound    = errors.New("product not found")
+	ErrDuplicateID = errors.New("product with this id already exists")
+)
+
 type PostgresStore struct {
 	db *sql.DB
 }
@@ -35,14 +44,37 @@ func (s *PostgresStore) Migrate() error {
 		id VARCHAR(50) PRIMARY KEY,
 		name VARCHAR(255) NOT NULL,
 		description TEXT,
-		price DECIMAL(10,2) NOT NULL,
+		price_cents BIGINT NOT NULL,
+		currency CHAR(3) NOT NULL DEFAULT 'USD',
 		image_url VARCHAR(500),
 		category VARCHAR(100),
 		created_at TIMESTAMP DEFAULT NOW(),
+		updated_at TIMESTAMP DEFAULT NOW(),
 		display_order INT DEFAULT 99
 	);
 	CREATE INDEX IF NOT EXISTS idx_products_category ON products(category);
 	CREATE INDEX IF NOT EXISTS idx_products_name ON products(name);
+
+	CREATE TABLE IF NOT EXISTS data_migrations (
+		name VARCHAR(100) PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+	`
+	if _, err := s.db.Exec(query); err != nil {
+		return err
+	}
+	// Convert the pre-money DECIMAL price column to integer cents
+	query = `
+	DO $$
+	BEGIN
+		IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'products' AND column_name = 'price') THEN
+			ALTER TABLE products ADD COLUMN IF NOT EXISTS price_cents BIGINT;
+			ALTER TABLE products ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+			UPDATE products SET price_cents = ROUND(price * 100) WHERE price_cents IS NULL;
+			ALTER TABLE products ALTER COLUMN price_cents SET NOT NULL;
+			ALTER TABLE products DROP COLUMN price;
+		END IF;
+	END $$;
 	`
 	if _, err := s.db.Exec(query); err != nil {
 		return err
@@ -50,18 +82,72 @@ func (s *PostgresStore) Migrate() error {
 	// Add display_order if migrating from old schema (before it existed)
 	_, _ = s.db.Exec("ALTER TABLE products ADD COLUMN IF NOT EXISTS display_order INT DEFAULT 99")
 	_, _ = s.db.Exec("CREATE INDEX IF NOT EXISTS idx_products_display_order ON products(display_order)")
-	// Set display order for featured products (Mug, Sticker, T-Shirt first)
-	_, _ = s.db.Exec(`UPDATE products SET display_order = 1 WHERE id = '5'`)
-	_, _ = s.db.Exec(`UPDATE products SET display_order = 2 WHERE id = '6'`)
-	_, _ = s.db.Exec(`UPDATE products SET display_order = 3 WHERE id = '1'`)
-	_, _ = s.db.Exec(`UPDATE products SET display_order = 4 WHERE id = '7'`)
-	_, _ = s.db.Exec(`UPDATE products SET display_order = 5 WHERE id = '2'`)
-	_, _ = s.db.Exec(`UPDATE products SET display_order = 6 WHERE id = '3'`)
-	_, _ = s.db.Exec(`UPDATE products SET display_order = 7 WHERE id = '4'`)
-	_, _ = s.db.Exec(`UPDATE products SET display_order = 8 WHERE id = '8'`)
-	_, _ = s.db.Exec(`UPDATE products SET display_order = 9 WHERE id = '9'`)
-	_, _ = s.db.Exec(`UPDATE products SET display_order = 10 WHERE id = '10'`)
-	return nil
+	if _, err := s.db.Exec(`ALTER TABLE products ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP DEFAULT NOW()`); err != nil {
+		return err
+	}
+	if err := s.migrateLifecycle(); err != nil {
+		return err
+	}
+	if err := s.migrateCategories(); err != nil {
+		return err
+	}
+	if err := s.migrateMerchandising(); err != nil {
+		return err
+	}
+	if err := s.migrateSearch(); err != nil {
+		return err
+	}
+	if err := s.migrateAttributes(); err != nil {
+		return err
+	}
+	if err := s.migrateVariants(); err != nil {
+		return err
+	}
+	if err := s.migrateCurrencies(); err != nil {
+		return err
+	}
+	if err := s.migratePrices(); err != nil {
+		return err
+	}
+	if err := s.migrateReviews(); err != nil {
+		return err
+	}
+	if err := s.migrateRelated(); err != nil {
+		return err
+	}
+	if err := s.migrateSuggest(); err != nil {
+		return err
+	}
+	if err := s.migrateTranslations(); err != nil {
+		return err
+	}
+	if err := s.migrateMedia(); err != nil {
+		return err
+	}
+	return s.migrateChanges()
+}
+
+// migrateOnce runs query, a change to data rather than schema, the first time a
+// database is migrated with name, so rows an admin later edits or deletes are not
+// put back on the next start. Concurrent replicas wait on the first one's name.
+func (s *PostgresStore) migrateOnce(name, query string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO data_migrations (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("data migration %q: %w", name, err)
+	}
+	return tx.Commit()
 }
 
 func (s *PostgresStore) Seed() error {
@@ -79,52 +165,57 @@ func (s *PostgresStore) Seed() error {
 		id           string
 		name         string
 		description  string
-		price        float64
+		priceCents   int64
 		imageURL     string
 		category     string
+		attributes   map[string]interface{}
 		displayOrder int
 	}{
 		{
 			id:           "5",
 			name:         "MetalBear Mug - Debug with Coffee",
 			description:  "Large 15oz ceramic mug perfect for your morning coffee or late-night debugging fuel. Microwave and dishwasher safe.",
-			price:        14.99,
+			priceCents:   1499,
 			imageURL:     "/images/mug.webp",
 			category:     "accessories",
+			attributes:   map[string]interface{}{"material": "ceramic", "capacity_oz": 15, "dishwasher_safe": true},
 			displayOrder: 1,
 		},
 		{
 			id:           "6",
 			name:         "MetalBear Sticker Pack",
 			description:  "Set of 10 high-quality vinyl stickers featuring MetalBear logos and developer humor. Perfect for laptops, water bottles, and keyboards.",
-			price:        9.99,
+			priceCents:   999,
 			imageURL:     "/images/stickers.webp",
 			category:     "accessories",
+			attributes:   map[string]interface{}{"material": "vinyl", "pack_size": 10},
 			displayOrder: 2,
 		},
 		{
 			id:           "1",
 			name:         "MetalBear Classic T-Shirt",
 			description:  "Classic black t-shirt featuring the iconic MetalBear logo. Made from 100% organic cotton for maximum comfort during those long debugging sessions.",
-			price:        29.99,
+			priceCents:   2999,
 			imageURL:     "/images/tshirt-classic.webp",
 			category:     "t-shirts",
+			attributes:   map[string]interface{}{"fabric": "organic cotton", "fit": "regular", "color": "black"},
 			displayOrder: 3,
 		},
 		{
 			id:           "7",
 			name:         "MetalBear Polo Shirt",
 			description:  "Professional polo shirt with subtle MetalBear branding. Perfect for when you need to look presentable but still rep your favorite tools.",
-			price:        44.99,
+			priceCents:   4499,
 			imageURL:     "/images/polo.webp",
 			category:     "t-shirts",
+			attributes:   map[string]interface{}{"fabric": "cotton pique", "fit": "regular"},
 			displayOrder: 4,
 		},
 		{
 			id:           "2",
 			name:         "MetalBear Logo Hoodie",
 			description:  "Stay warm while coding with this premium hoodie. Features the MetalBear logo on the front and 'Debug Locally, Ship Globally' on the back.",
-			price:        59.99,
+			priceCents:   5999,
 			imageURL:     "/images/hoodie-logo.webp",
 			category:     "hoodies",
 			displayOrder: 5,
@@ -133,16 +224,17 @@ func (s *PostgresStore) Seed() error {
 			id:           "3",
 			name:         "MetalBear Dev T-Shirt - Works on My Machine",
 			description:  "The classic developer excuse, now on a shirt. Perfect for standup meetings and code reviews.",
-			price:        29.99,
+			priceCents:   2999,
 			imageURL:     "/images/tshirt-works.webp",
 			category:     "t-shirts",
+			attributes:   map[string]interface{}{"fabric": "cotton", "fit": "regular"},
 			displayOrder: 6,
 		},
 		{
 			id:           "4",
 			name:         "MetalBear Cap",
 			description:  "Adjustable snapback cap with embroidered MetalBear logo. Shield your eyes from the glare of your monitor.",
-			price:        24.99,
+			priceCents:   2499,
 			imageURL:     "/images/cap.webp",
 			category:     "accessories",
 			displayOrder: 7,
@@ -151,113 +243,224 @@ func (s *PostgresStore) Seed() error {
 			id:           "8",
 			name:         "MetalBear Zip Hoodie",
 			description:  "Premium zip-up hoodie with MetalBear logo. Features deep pockets for your phone and snacks. Available in charcoal grey.",
-			price:        69.99,
+			priceCents:   6999,
 			imageURL:     "/images/hoodie-zip.webp",
 			category:     "hoodies",
+			attributes:   map[string]interface{}{"color": "charcoal grey"},
 			displayOrder: 8,
 		},
 		{
 			id:           "9",
 			name:         "MetalBear Socks - 3 Pack",
 			description:  "Comfortable crew socks with MetalBear patterns. Because even your feet deserve good developer swag.",
-			price:        19.99,
+			priceCents:   1999,
 			imageURL:     "/images/socks.webp",
 			category:     "accessories",
+			attributes:   map[string]interface{}{"pack_size": 3},
 			displayOrder: 9,
 		},
 		{
 			id:           "10",
 			name:         "MetalBear Beanie",
 			description:  "Warm knit beanie with embroidered MetalBear logo. Perfect for cold server rooms and winter debugging.",
-			price:        22.99,
+			priceCents:   2299,
 			imageURL:     "/images/beanie.webp",
 			category:     "accessories",
 			displayOrder: 10,
 		},
 	}
 
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	for _, p := range products {
-		_, err := s.db.Exec(
-			`INSERT INTO products (id, name, description, price, image_url, category, display_order) VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (id) DO UPDATE SET display_order = EXCLUDED.display_order`,
-			p.id, p.name, p.description, p.price, p.imageURL, p.category, p.displayOrder,
+		attributes, err := marshalAttributes(p.attributes)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(
+			`INSERT INTO products (id, name, description, price_cents, currency, image_url, category, attributes, display_order) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) ON CONFLICT (id) DO UPDATE SET display_order = EXCLUDED.display_order`,
+			p.id, p.name, p.description, p.priceCents, money.DefaultCurrency, p.imageURL, p.category, attributes, p.displayOrder,
 		)
 		if err != nil {
 			return fmt.Errorf("failed to seed product %s: %w", p.name, err)
 		}
+		if err := recordListPrice(tx, p.id); err != nil {
+			return fmt.Errorf("failed to seed price history of %s: %w", p.name, err)
+		}
+		if err := syncPrimaryMedia(tx, p.id); err != nil {
+			return fmt.Errorf("failed to seed images of %s: %w", p.name, err)
+		}
 	}
 
-	return nil
+	return tx.Commit()
 }
 
-func (s *PostgresStore) ListProducts() ([]models.Product, error) {
-	rows, err := s.db.Query(`
-		SELECT id, name, description, price, image_url, category, created_at
-		FROM products
-		ORDER BY COALESCE(display_order, 99), id
-	`)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+// ListProducts pages through the products visible on the storefront.
+func (s *PostgresStore) ListProducts(opts models.ListOptions) (*models.ProductPage, error) {
+	return s.page(newLiveProductQuery(), opts)
+}
 
-	return scanProducts(rows)
+// ListAllProducts pages through every product regardless of lifecycle state, optionally
+// limited to one status.
+func (s *PostgresStore) ListAllProducts(status string, opts models.ListOptions) (*models.ProductPage, error) {
+	q := newProductQuery()
+	if status != "" {
+		q.where = append(q.where, "p.status = "+q.arg(status))
+	}
+	return s.page(q, opts)
 }
 
 func (s *PostgresStore) GetProduct(id string) (*models.Product, error) {
 	var p models.Product
-	err := s.db.QueryRow(`
-		SELECT id, name, description, price, image_url, category, created_at
-		FROM products
-		WHERE id = $1
-	`, id).Scan(&p.ID, &p.Name, &p.Description, &p.Price, &p.ImageURL, &p.Category, &p.CreatedAt)
+	err := scanProduct(s.db.QueryRow(`SELECT `+productColumns+` FROM `+productsFrom+` WHERE p.id = $1`, id), &p)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
+
+	if p.Variants, err = s.ListVariants(id); err != nil {
+		return nil, err
+	}
+	if p.Media, err = s.ListMedia(id); err != nil {
+		return nil, err
+	}
 	return &p, nil
 }
 
-func (s *PostgresStore) SearchProducts(query string) ([]models.Product, error) {
-	rows, err := s.db.Query(`
-		SELECT id, name, description, price, image_url, category, created_at
-		FROM products
-		WHERE name ILIKE $1 OR description ILIKE $1
-		ORDER BY COALESCE(display_order, 99), id
-	`, "%"+query+"%")
+func (s *PostgresStore) ListByCategory(category string, opts models.ListOptions) (*models.ProductPage, error) {
+	q := newLiveProductQuery()
+	slug := q.arg(category)
+	q.from = productsFrom + " LEFT JOIN category_rankings cr ON cr.category = " + slug + " AND cr.product_id = p.id"
+	q.where = append(q.where, "p.category IN "+categorySubtree("slug = "+slug))
+	q.defaultSort = sortCategoryRanked
+	return s.page(q, opts)
+}
+
+func (s *PostgresStore) CreateProduct(in models.ProductInput) (*models.Product, error) {
+	if in.ID == "" {
+		in.ID = uuid.New().String()
+	}
+	if in.Status == "" {
+		in.Status = models.StatusPublished
+	}
+
+	attributes, err := marshalAttributes(in.Attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	defer tx.Rollback()
 
-	return scanProducts(rows)
+	_, err = tx.Exec(`
+		INSERT INTO products (id, name, description, price_cents, currency, image_url, category, attributes, status, publish_at, unpublish_at, display_order)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9, $10, $11, (SELECT COALESCE(MAX(display_order), 0) + 1 FROM products))
+	`, in.ID, in.Name, in.Description, in.Price.Amount, in.Price.Currency, in.ImageURL, in.Category, attributes, in.Status, in.PublishAt, in.UnpublishAt)
+	if err != nil {
+		return nil, productWriteError(err)
+	}
+	if err := recordListPrice(tx, in.ID); err != nil {
+		return nil, err
+	}
+	if err := syncPrimaryMedia(tx, in.ID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.GetProduct(in.ID)
 }
 
-func (s *PostgresStore) ListByCategory(category string) ([]models.Product, error) {
-	rows, err := s.db.Query(`
-		SELECT id, name, description, price, image_url, category, created_at
-		FROM products
-		WHERE category = $1
-		ORDER BY COALESCE(display_order, 99), id
-	`, category)
+func (s *PostgresStore) UpdateProduct(id string, in models.ProductInput) (*models.Product, error) {
+	attributes, err := marshalAttributes(in.Attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	defer tx.Rollback()
 
-	return scanProducts(rows)
+	res, err := tx.Exec(`
+		UPDATE products
+		SET name = $2, description = $3, price_cents = $4, currency = $5, image_url = $6, category = NULLIF($7, ''), attributes = $8,
+			status = COALESCE(NULLIF($9, ''), status), publish_at = $10, unpublish_at = $11, updated_at = NOW()
+		WHERE id = $1
+	`, id, in.Name, in.Description, in.Price.Amount, in.Price.Currency, in.ImageURL, in.Category, attributes, in.Status, in.PublishAt, in.UnpublishAt)
+	if err != nil {
+		return nil, productWriteError(err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrNotFound
+	}
+	if err := recordListPrice(tx, id); err != nil {
+		return nil, err
+	}
+	if err := syncPrimaryMedia(tx, id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.GetProduct(id)
 }
 
-func scanProducts(rows *sql.Rows) ([]models.Product, error) {
-	var products []models.Product
-	for rows.Next() {
-		var p models.Product
-		err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.Price, &p.ImageURL, &p.Category, &p.CreatedAt)
-		if err != nil {
-			return nil, err
-		}
-		products = append(products, p)
+func (s *PostgresStore) DeleteProduct(id string) error {
+	res, err := s.db.Exec(`DELETE FROM products WHERE id = $1`, id)
+	if err != nil {
+		return err
 	}
-	if products == nil {
-		products = []models.Product{}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// productWriteError maps a duplicate id to ErrDuplicateID, and an unknown category or a
+// currency change under variant price overrides to a validation error.
+func productWriteError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+	switch {
+	case pqErr.Code == "23505":
+		return ErrDuplicateID
+	case pqErr.Code == "23503" && pqErr.Constraint == "product_variants_override_currency_fkey":
+		return fmt.Errorf("%w: currency cannot change while variants have a price_override", models.ErrValidation)
+	case pqErr.Code == "23503":
+		return fmt.Errorf("%w: unknown category", models.ErrValidation)
+	}
+	return err
+}
+
+// touchProduct bumps updated_at after a change to data served with the product, such
+// as its variants or price list.
+func (s *PostgresStore) touchProduct(id string) error {
+	_, err := s.db.Exec(`UPDATE products SET updated_at = NOW() WHERE id = $1`, id)
+	return err
+}
+
+func marshalAttributes(attributes map[string]interface{}) ([]byte, error) {
+	if attributes == nil {
+		return []byte("{}"), nil
 	}
-	return products, rows.Err()
+	return json.Marshal(attributes)
 }
@@ -0,0 +1,191 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/metalbear-co/metalmart/pkg/money"
+	"github.com/metalbear-co/metalmart/services/catalogue/models"
+)
+
+var (
+	ErrPriceNotFound = errors.New("scheduled price not found")
+	ErrPriceEnded    = errors.New("scheduled price has already ended")
+)
+
+// scheduledPriceJoin joins the scheduled price in effect now as sp. When scheduled
+// prices overlap, the one that started last wins.
+const scheduledPriceJoin = `LEFT JOIN LATERAL (
+	SELECT pp.price_cents, pp.compare_at_cents FROM product_prices pp
+	WHERE pp.product_id = p.id AND pp.kind = 'scheduled' AND pp.currency = p.currency
+		AND pp.effective_from <= NOW() AND (pp.effective_to IS NULL OR pp.effective_to > NOW())
+	ORDER BY pp.effective_from DESC, pp.id DESC
+	LIMIT 1
+) sp ON true`
+
+// productsFrom is the FROM clause every product read starts with.
+const productsFrom = `products p ` + scheduledPriceJoin
+
+const (
+	// effectivePrice is the SQL form of models.Product.Price.
+	effectivePrice = `COALESCE(sp.price_cents, p.price_cents)`
+	// compareAtPrice is the scheduled price's compare_at, defaulting to the list price,
+	// when it is higher than the scheduled price.
+	compareAtPrice = `CASE WHEN sp.price_cents < COALESCE(sp.compare_at_cents, p.price_cents) THEN COALESCE(sp.compare_at_cents, p.price_cents) END`
+	// variantPrice is the SQL form of models.Variant.Price: a scheduled price reduces a
+	// variant's override in proportion to the product's list price.
+	variantPrice = `COALESCE(ROUND(v.price_override_cents * sp.price_cents::numeric / p.price_cents)::bigint, v.price_override_cents, ` + effectivePrice + `)`
+	// variantCompareAt is the product's compare_at for a variant without an override,
+	// and the override scaled the same way as variantPrice for one with.
+	variantCompareAt = `CASE WHEN v.price_override_cents IS NULL THEN ` + compareAtPrice + `
+		WHEN sp.price_cents < COALESCE(sp.compare_at_cents, p.price_cents) THEN ROUND(v.price_override_cents * COALESCE(sp.compare_at_cents, p.price_cents)::numeric / p.price_cents)::bigint END`
+)
+
+// migratePrices creates the price history and records each product's current price
+// as its first list entry.
+func (s *PostgresStore) migratePrices() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS product_prices (
+		id BIGSERIAL PRIMARY KEY,
+		product_id VARCHAR(50) NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+		kind VARCHAR(20) NOT NULL,
+		price_cents BIGINT NOT NULL CHECK (price_cents > 0),
+		currency CHAR(3) NOT NULL,
+		compare_at_cents BIGINT,
+		effective_from TIMESTAMPTZ NOT NULL,
+		effective_to TIMESTAMPTZ,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		CHECK (effective_to IS NULL OR effective_to > effective_from)
+	);
+	CREATE INDEX IF NOT EXISTS idx_product_prices_product_from ON product_prices(product_id, effective_from);
+	CREATE INDEX IF NOT EXISTS idx_product_prices_scheduled_from ON product_prices(effective_from) WHERE kind = 'scheduled';
+	CREATE INDEX IF NOT EXISTS idx_product_prices_scheduled_to ON product_prices(effective_to) WHERE kind = 'scheduled';
+
+	INSERT INTO product_prices (product_id, kind, price_cents, currency, effective_from)
+	SELECT p.id, 'list', p.price_cents, p.currency, COALESCE(p.created_at, NOW()) FROM products p
+	WHERE NOT EXISTS (SELECT 1 FROM product_prices pp WHERE pp.product_id = p.id AND pp.kind = 'list');
+	`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// recordListPrice closes the product's open list entry and opens a new one if the
+// product's price or currency no longer match it. Both statements see the same
+// snapshot, so an unchanged price writes nothing.
+func recordListPrice(db execer, productID string) error {
+	_, err := db.Exec(`
+		WITH closed AS (
+			UPDATE product_prices pp SET effective_to = NOW()
+			FROM products p
+			WHERE p.id = $1 AND pp.product_id = p.id AND pp.kind = 'list' AND pp.effective_to IS NULL
+				AND (pp.price_cents, pp.currency) <> (p.price_cents, p.currency)
+		)
+		INSERT INTO product_prices (product_id, kind, price_cents, currency, effective_from)
+		SELECT p.id, 'list', p.price_cents, p.currency, NOW() FROM products p
+		WHERE p.id = $1 AND NOT EXISTS (
+			SELECT 1 FROM product_prices pp
+			WHERE pp.product_id = p.id AND pp.kind = 'list' AND pp.effective_to IS NULL
+				AND pp.price_cents = p.price_cents AND pp.currency = p.currency
+		)
+	`, productID)
+	return err
+}
+
+const priceChangeColumns = `id, product_id, kind, price_cents, currency, compare_at_cents, effective_from, effective_to, created_at`
+
+func scanPriceChange(row rowScanner, c *models.PriceChange) error {
+	var compareAt sql.NullInt64
+	err := row.Scan(&c.ID, &c.ProductID, &c.Kind, &c.Price.Amount, &c.Price.Currency, &compareAt, &c.EffectiveFrom, &c.EffectiveTo, &c.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if compareAt.Valid {
+		c.CompareAt = &money.Money{Amount: compareAt.Int64, Currency: c.Price.Currency}
+	}
+	return nil
+}
+
+// PriceHistory returns every list and scheduled price of a product, latest first.
+func (s *PostgresStore) PriceHistory(productID string) ([]models.PriceChange, error) {
+	if _, err := s.GetProduct(productID); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(`
+		SELECT `+priceChangeColumns+` FROM product_prices
+		WHERE product_id = $1
+		ORDER BY effective_from DESC, id DESC
+	`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []models.PriceChange{}
+	for rows.Next() {
+		var c models.PriceChange
+		if err := scanPriceChange(rows, &c); err != nil {
+			return nil, err
+		}
+		history = append(history, c)
+	}
+	return history, rows.Err()
+}
+
+// SchedulePrice adds a scheduled price to a product. The input must already be
+// normalized and validated against the product's currency.
+func (s *PostgresStore) SchedulePrice(productID string, in models.ScheduledPriceInput) (*models.PriceChange, error) {
+	var compareAt *int64
+	if in.CompareAt != nil {
+		compareAt = &in.CompareAt.Amount
+	}
+
+	var c models.PriceChange
+	err := scanPriceChange(s.db.QueryRow(`
+		INSERT INTO product_prices (product_id, kind, price_cents, currency, compare_at_cents, effective_from, effective_to)
+		VALUES ($1, 'scheduled', $2, $3, $4, $5, $6)
+		RETURNING `+priceChangeColumns,
+		productID, in.Price.Amount, in.Price.Currency, compareAt, in.EffectiveFrom, in.EffectiveTo), &c)
+	if err != nil {
+		return nil, productWriteError(err)
+	}
+	if err := s.touchProduct(productID); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// CancelScheduledPrice removes a scheduled price that has not started yet and ends
+// one in effect now, keeping it in the history.
+func (s *PostgresStore) CancelScheduledPrice(productID string, priceID int64) error {
+	var from time.Time
+	var to sql.NullTime
+	err := s.db.QueryRow(`
+		SELECT effective_from, effective_to FROM product_prices
+		WHERE product_id = $1 AND id = $2 AND kind = 'scheduled'
+	`, productID, priceID).Scan(&from, &to)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrPriceNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	switch {
+	case to.Valid && !to.Time.After(now):
+		return ErrPriceEnded
+	case from.After(now):
+		_, err = s.db.Exec(`DELETE FROM product_prices WHERE id = $1`, priceID)
+	default:
+		_, err = s.db.Exec(`UPDATE product_prices SET effective_to = NOW() WHERE id = $1`, priceID)
+	}
+	if err != nil {
+		return err
+	}
+	return s.touchProduct(productID)
+}
@@ -0,0 +1,271 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/metalbear-co/metalmart/pkg/money"
+	"github.com/metalbear-co/metalmart/services/catalogue/models"
+)
+
+const productColumns = `p.id, p.name, p.description, ` + effectivePrice + `, p.currency, ` + compareAtPrice + `, p.price_cents, p.image_url, COALESCE(p.category, ''), p.attributes, p.status, p.publish_at, p.unpublish_at, p.rating_avg, p.review_count, p.created_at, p.updated_at`
+
+// liveProducts is the storefront visibility rule, the SQL form of models.Product.Live.
+const liveProducts = `p.status = 'published' AND (p.publish_at IS NULL OR p.publish_at <= NOW()) AND (p.unpublish_at IS NULL OR p.unpublish_at > NOW())`
+
+// sortKey is one column of a keyset ordering. cast is the type used to compare the
+// column against the value stored in a cursor.
+type sortKey struct {
+	expr string
+	cast string
+}
+
+type sortSpec struct {
+	keys []sortKey
+	desc bool
+}
+
+var (
+	// sortMerchandised is the default storefront order: featured pins, then display order.
+	sortMerchandised = sortSpec{keys: []sortKey{
+		{"COALESCE(p.featured_rank, 2147483647)", "int"},
+		{"COALESCE(p.display_order, 99)", "int"},
+		{"p.id", "text"},
+	}}
+	// sortCategoryRanked puts the category ranking ahead of the default order.
+	sortCategoryRanked = sortSpec{keys: []sortKey{
+		{"COALESCE(cr.position, 2147483647)", "int"},
+		{"COALESCE(p.featured_rank, 2147483647)", "int"},
+		{"COALESCE(p.display_order, 99)", "int"},
+		{"p.id", "text"},
+	}}
+
+	sortSpecs = map[string]sortSpec{
+		models.SortPriceAsc:  {keys: []sortKey{{effectivePrice, "bigint"}, {"p.id", "text"}}},
+		models.SortPriceDesc: {keys: []sortKey{{effectivePrice, "bigint"}, {"p.id", "text"}}, desc: true},
+		models.SortNewest:    {keys: []sortKey{{"p.created_at", "timestamp"}, {"p.id", "text"}}, desc: true},
+		models.SortName:      {keys: []sortKey{{"p.name", "text"}, {"p.id", "text"}}},
+	}
+)
+
+// productQuery builds a paginated SELECT over products aliased as p. columns are
+// selected after productColumns.
+type productQuery struct {
+	from        string
+	columns     []string
+	where       []string
+	args        []interface{}
+	defaultSort sortSpec
+}
+
+func newProductQuery() *productQuery {
+	return &productQuery{from: productsFrom, defaultSort: sortMerchandised}
+}
+
+// newLiveProductQuery is newProductQuery restricted to products visible on the storefront.
+func newLiveProductQuery() *productQuery {
+	q := newProductQuery()
+	q.where = append(q.where, liveProducts)
+	return q
+}
+
+// arg adds a bind parameter and returns its placeholder.
+func (q *productQuery) arg(v interface{}) string {
+	q.args = append(q.args, v)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+func (q *productQuery) whereClause() string {
+	if len(q.where) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(q.where, " AND ")
+}
+
+// cursor is the decoded form of the opaque next_cursor token: the sort it was issued
+// for and the sort key values of the last row on the previous page.
+type cursor struct {
+	Sort string   `json:"s"`
+	Keys []string `json:"k"`
+}
+
+func encodeCursor(c cursor) string {
+	return encodeToken(c)
+}
+
+func decodeCursor(token string) (cursor, error) {
+	var c cursor
+	err := decodeToken(token, &c)
+	return c, err
+}
+
+// encodeToken and decodeToken convert a page position to and from an opaque token.
+func encodeToken(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeToken(token string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return fmt.Errorf("%w: invalid cursor", models.ErrValidation)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: invalid cursor", models.ErrValidation)
+	}
+	return nil
+}
+
+// sortKeyValues converts the JSON array produced by json_build_array back into the
+// textual values that are bound as cursor parameters.
+func sortKeyValues(raw []byte) ([]string, error) {
+	var parts []json.RawMessage
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return nil, err
+	}
+	values := make([]string, len(parts))
+	for i, part := range parts {
+		if len(part) > 0 && part[0] == '"' {
+			if err := json.Unmarshal(part, &values[i]); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		values[i] = string(part)
+	}
+	return values, nil
+}
+
+// page runs the query with keyset pagination and returns one page of products plus
+// the total count of matching rows.
+func (s *PostgresStore) page(q *productQuery, opts models.ListOptions) (*models.ProductPage, error) {
+	result := &models.ProductPage{Products: []models.Product{}}
+	var err error
+	result.NextCursor, result.Total, err = s.pageRows(q, opts, func(row rowScanner) error {
+		var p models.Product
+		if err := scanProduct(row, &p); err != nil {
+			return err
+		}
+		result.Products = append(result.Products, p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// keyedRow appends the sort key column to every Scan so callers only deal with
+// productColumns and their own q.columns.
+type keyedRow struct {
+	rows *sql.Rows
+	key  *[]byte
+}
+
+func (r keyedRow) Scan(dest ...interface{}) error {
+	return r.rows.Scan(append(dest, r.key)...)
+}
+
+// pageRows runs the query with keyset pagination, calling scan for each row of the
+// page. It returns the cursor of the next page ("" on the last page) and the total
+// count of matching rows.
+func (s *PostgresStore) pageRows(q *productQuery, opts models.ListOptions, scan func(row rowScanner) error) (string, int, error) {
+	spec := q.defaultSort
+	if opts.Sort != "" {
+		custom, ok := sortSpecs[opts.Sort]
+		if !ok {
+			return "", 0, fmt.Errorf("%w: unknown sort %q", models.ErrValidation, opts.Sort)
+		}
+		spec = custom
+	}
+
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM `+q.from+q.whereClause(), q.args...).Scan(&total); err != nil {
+		return "", 0, err
+	}
+
+	exprs := make([]string, len(spec.keys))
+	for i, k := range spec.keys {
+		exprs[i] = k.expr
+	}
+
+	if opts.Cursor != "" {
+		c, err := decodeCursor(opts.Cursor)
+		if err != nil {
+			return "", 0, err
+		}
+		if c.Sort != opts.Sort || len(c.Keys) != len(spec.keys) {
+			return "", 0, fmt.Errorf("%w: cursor does not match sort", models.ErrValidation)
+		}
+		placeholders := make([]string, len(spec.keys))
+		for i, k := range spec.keys {
+			placeholders[i] = q.arg(c.Keys[i]) + "::" + k.cast
+		}
+		op := ">"
+		if spec.desc {
+			op = "<"
+		}
+		q.where = append(q.where, fmt.Sprintf("(%s) %s (%s)", strings.Join(exprs, ", "), op, strings.Join(placeholders, ", ")))
+	}
+
+	direction := " ASC"
+	if spec.desc {
+		direction = " DESC"
+	}
+	order := make([]string, len(exprs))
+	for i, e := range exprs {
+		order[i] = e + direction
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = models.DefaultPageSize
+	}
+
+	columns := append([]string{productColumns}, q.columns...)
+	query := fmt.Sprintf(`SELECT %s, json_build_array(%s) FROM %s%s ORDER BY %s LIMIT %d`,
+		strings.Join(columns, ", "), strings.Join(exprs, ", "), q.from, q.whereClause(), strings.Join(order, ", "), limit+1)
+	rows, err := s.db.Query(query, q.args...)
+	if err != nil {
+		return "", 0, err
+	}
+	defer rows.Close()
+
+	var lastKey []byte
+	row := keyedRow{rows: rows, key: &lastKey}
+	for n := 0; rows.Next(); n++ {
+		if n == limit {
+			keys, err := sortKeyValues(lastKey)
+			if err != nil {
+				return "", 0, err
+			}
+			return encodeCursor(cursor{Sort: opts.Sort, Keys: keys}), total, rows.Err()
+		}
+		if err := scan(row); err != nil {
+			return "", 0, err
+		}
+	}
+	return "", total, rows.Err()
+}
+
+// scanProduct scans productColumns into p followed by any extra destinations.
+func scanProduct(row rowScanner, p *models.Product, extra ...interface{}) error {
+	var attributes []byte
+	var compareAt sql.NullInt64
+	dest := []interface{}{&p.ID, &p.Name, &p.Description, &p.Price.Amount, &p.Price.Currency, &compareAt, &p.ListPrice.Amount, &p.ImageURL, &p.Category, &attributes, &p.Status, &p.PublishAt, &p.UnpublishAt, &p.Rating, &p.ReviewCount, &p.CreatedAt, &p.UpdatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	p.ListPrice.Currency = p.Price.Currency
+	if compareAt.Valid {
+		p.CompareAt = &money.Money{Amount: compareAt.Int64, Currency: p.Price.Currency}
+	}
+	return json.Unmarshal(attributes, &p.Attributes)
+}
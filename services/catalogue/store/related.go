@@ -0,0 +1,127 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/metalbear-co/metalmart/services/catalogue/models"
+)
+
+// migrateRelated creates the co-purchase statistics fed from order.created events.
+// Each unordered pair of products bought in one order is stored in both directions.
+// Orders are recorded once so redelivered events are not counted twice. There are no
+// foreign keys: orders may reference products deleted since.
+func (s *PostgresStore) migrateRelated() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS product_copurchases (
+		product_id VARCHAR(50) NOT NULL,
+		related_id VARCHAR(50) NOT NULL,
+		order_count INT NOT NULL DEFAULT 0,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (product_id, related_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS copurchase_orders (
+		order_id VARCHAR(50) PRIMARY KEY,
+		recorded_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+// RecordOrder adds the products of one order to the co-purchase statistics. It
+// reports false when the order was already recorded.
+func (s *PostgresStore) RecordOrder(orderID string, productIDs []string) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO copurchase_orders (order_id) VALUES ($1) ON CONFLICT (order_id) DO NOTHING`, orderID)
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
+	seen := map[string]bool{}
+	var ids []string
+	for _, id := range productIDs {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	// A fixed order keeps concurrent upserts of the same pairs from deadlocking.
+	sort.Strings(ids)
+	for _, a := range ids {
+		for _, b := range ids {
+			if a == b {
+				continue
+			}
+			_, err := tx.Exec(`
+				INSERT INTO product_copurchases (product_id, related_id, order_count) VALUES ($1, $2, 1)
+				ON CONFLICT (product_id, related_id) DO UPDATE
+				SET order_count = product_copurchases.order_count + 1, updated_at = NOW()
+			`, a, b)
+			if err != nil {
+				return false, err
+			}
+		}
+	}
+	return true, tx.Commit()
+}
+
+// copurchaseBackfill is the data migration recording the orders placed before the
+// catalogue consumed order.created events.
+const copurchaseBackfill = "backfill co-purchases"
+
+// CopurchasesBackfilled reports whether the order history has been backfilled into
+// the co-purchase statistics.
+func (s *PostgresStore) CopurchasesBackfilled() (bool, error) {
+	var done bool
+	err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM data_migrations WHERE name = $1)`, copurchaseBackfill).Scan(&done)
+	return done, err
+}
+
+// MarkCopurchasesBackfilled records that the order history has been backfilled.
+func (s *PostgresStore) MarkCopurchasesBackfilled() error {
+	_, err := s.db.Exec(`INSERT INTO data_migrations (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`, copurchaseBackfill)
+	return err
+}
+
+// RelatedProducts recommends up to limit live products for a product page: first the
+// products most often bought together with it, then products from the same category
+// closest to it in price.
+func (s *PostgresStore) RelatedProducts(id string, limit int) ([]models.RelatedProduct, error) {
+	rows, err := s.db.Query(fmt.Sprintf(`
+		SELECT %s, COALESCE(cp.order_count, 0)
+		FROM %s
+		JOIN products src ON src.id = $1
+		LEFT JOIN product_copurchases cp ON cp.product_id = src.id AND cp.related_id = p.id
+		WHERE %s AND p.id <> src.id AND (cp.order_count > 0 OR p.category = src.category)
+		ORDER BY COALESCE(cp.order_count, 0) DESC, ABS(%s - src.price_cents), p.id
+		LIMIT $2
+	`, productColumns, productsFrom, liveProducts, effectivePrice), id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	related := []models.RelatedProduct{}
+	for rows.Next() {
+		var r models.RelatedProduct
+		if err := scanProduct(rows, &r.Product, &r.CoPurchases); err != nil {
+			return nil, err
+		}
+		r.Reason = models.RelatedSameCategory
+		if r.CoPurchases > 0 {
+			r.Reason = models.RelatedBoughtTogether
+		}
+		related = append(related, r)
+	}
+	return related, rows.Err()
+}
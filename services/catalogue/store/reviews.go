@@ -0,0 +1,176 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/metalbear-co/metalmart/services/catalogue/models"
+)
+
+var (
+	ErrReviewNotFound  = errors.New("review not found")
+	ErrDuplicateReview = errors.New("this customer has already reviewed the product")
+)
+
+// migrateReviews creates review storage and the rating summary kept on products.
+// The summary only counts approved reviews and is recomputed whenever a review is
+// moderated.
+func (s *PostgresStore) migrateReviews() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS product_reviews (
+		id VARCHAR(50) PRIMARY KEY,
+		product_id VARCHAR(50) NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+		rating SMALLINT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+		title VARCHAR(200) NOT NULL DEFAULT '',
+		body TEXT NOT NULL,
+		author_name VARCHAR(100) NOT NULL,
+		author_email VARCHAR(255) NOT NULL,
+		verified_purchase BOOLEAN NOT NULL DEFAULT FALSE,
+		status VARCHAR(20) NOT NULL DEFAULT 'pending',
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		UNIQUE (product_id, author_email)
+	);
+	CREATE INDEX IF NOT EXISTS idx_product_reviews_product_status ON product_reviews(product_id, status, created_at);
+	CREATE INDEX IF NOT EXISTS idx_product_reviews_status ON product_reviews(status, created_at);
+
+	ALTER TABLE products ADD COLUMN IF NOT EXISTS rating_avg NUMERIC(3, 2) NOT NULL DEFAULT 0;
+	ALTER TABLE products ADD COLUMN IF NOT EXISTS review_count INT NOT NULL DEFAULT 0;
+	`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+const reviewColumns = `id, product_id, rating, title, body, author_name, author_email, verified_purchase, status, created_at, updated_at`
+
+func scanReview(row rowScanner, r *models.Review) error {
+	return row.Scan(&r.ID, &r.ProductID, &r.Rating, &r.Title, &r.Body, &r.AuthorName, &r.AuthorEmail, &r.VerifiedPurchase, &r.Status, &r.CreatedAt, &r.UpdatedAt)
+}
+
+// CreateReview stores a review awaiting moderation. A customer can review each
+// product once.
+func (s *PostgresStore) CreateReview(productID string, in models.ReviewInput, verified bool) (*models.Review, error) {
+	var r models.Review
+	err := scanReview(s.db.QueryRow(`
+		INSERT INTO product_reviews (id, product_id, rating, title, body, author_name, author_email, verified_purchase)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING `+reviewColumns,
+		uuid.New().String(), productID, in.Rating, in.Title, in.Body, in.AuthorName, in.AuthorEmail, verified), &r)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "23505":
+			return nil, ErrDuplicateReview
+		case "23503":
+			return nil, ErrNotFound
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// reviewCursor is the sort key of the last review on a page; reviews are listed
+// newest first.
+type reviewCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        string    `json:"i"`
+}
+
+// ListReviews pages through reviews, newest first. An empty productID lists reviews
+// of every product and an empty status reviews in every state.
+func (s *PostgresStore) ListReviews(productID, status string, opts models.ListOptions) (*models.ReviewPage, error) {
+	if opts.Sort != "" {
+		return nil, fmt.Errorf("%w: reviews cannot be sorted", models.ErrValidation)
+	}
+	where := `($1::text = '' OR product_id = $1) AND ($2::text = '' OR status = $2)`
+	args := []interface{}{productID, status}
+
+	page := &models.ReviewPage{Reviews: []models.Review{}}
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM product_reviews WHERE `+where, args...).Scan(&page.Total); err != nil {
+		return nil, err
+	}
+
+	if opts.Cursor != "" {
+		var c reviewCursor
+		if err := decodeToken(opts.Cursor, &c); err != nil {
+			return nil, err
+		}
+		where += ` AND (created_at, id) < ($3, $4)`
+		args = append(args, c.CreatedAt, c.ID)
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = models.DefaultPageSize
+	}
+
+	rows, err := s.db.Query(fmt.Sprintf(`
+		SELECT %s FROM product_reviews WHERE %s
+		ORDER BY created_at DESC, id DESC
+		LIMIT %d
+	`, reviewColumns, where, limit+1), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r models.Review
+		if err := scanReview(rows, &r); err != nil {
+			return nil, err
+		}
+		page.Reviews = append(page.Reviews, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(page.Reviews) > limit {
+		page.Reviews = page.Reviews[:limit]
+		last := page.Reviews[limit-1]
+		page.NextCursor = encodeToken(reviewCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+	return page, nil
+}
+
+// ModerateReview sets a review's moderation state and refreshes the product's rating.
+func (s *PostgresStore) ModerateReview(reviewID, status string) (*models.Review, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var r models.Review
+	err = scanReview(tx.QueryRow(`
+		UPDATE product_reviews SET status = $2, updated_at = NOW() WHERE id = $1
+		RETURNING `+reviewColumns, reviewID, status), &r)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrReviewNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE products p SET
+			rating_avg = COALESCE(r.avg, 0), review_count = r.count, updated_at = NOW()
+		FROM (
+			SELECT AVG(rating) AS avg, COUNT(*) AS count FROM product_reviews
+			WHERE product_id = $1 AND status = 'approved'
+		) r
+		WHERE p.id = $1
+	`, r.ProductID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
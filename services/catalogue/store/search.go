@@ -0,0 +1,260 @@
+package store
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/metalbear-co/metalmart/pkg/money"
+	"github.com/metalbear-co/metalmart/services/catalogue/models"
+)
+
+// priceBucketEdges are the boundaries of the price range facet in minor units.
+var priceBucketEdges = []int64{1000, 2500, 5000, 10000}
+
+// migrateSearch adds a weighted tsvector over name (A) and description (B). ts_rank's
+// default weights make a name match count 2.5x a description match.
+func (s *PostgresStore) migrateSearch() error {
+	query := `
+	ALTER TABLE products ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+		setweight(to_tsvector('english', COALESCE(name, '')), 'A') ||
+		setweight(to_tsvector('english', COALESCE(description, '')), 'B')
+	) STORED;
+	CREATE INDEX IF NOT EXISTS idx_products_search_vector ON products USING GIN(search_vector);
+	`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+// migrateAttributes adds the JSONB attributes searched by attr.<name> filters and, once,
+// gives the seed products of databases created before it their attributes. Products
+// that already have attributes keep them.
+func (s *PostgresStore) migrateAttributes() error {
+	query := `
+	ALTER TABLE products ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}';
+	CREATE INDEX IF NOT EXISTS idx_products_attributes ON products USING GIN(attributes);
+	`
+	if _, err := s.db.Exec(query); err != nil {
+		return err
+	}
+
+	return s.migrateOnce("seed product attributes", `
+		UPDATE products p SET attributes = seed.attributes::jsonb
+		FROM (VALUES
+			('5', '{"material": "ceramic", "capacity_oz": 15, "dishwasher_safe": true}'),
+			('6', '{"material": "vinyl", "pack_size": 10}'),
+			('1', '{"fabric": "organic cotton", "fit": "regular", "color": "black"}'),
+			('7', '{"fabric": "cotton pique", "fit": "regular"}'),
+			('3', '{"fabric": "cotton", "fit": "regular"}'),
+			('8', '{"color": "charcoal grey"}'),
+			('9', '{"pack_size": 3}')
+		) AS seed(id, attributes)
+		WHERE p.id = seed.id AND p.attributes = '{}'
+	`)
+}
+
+const (
+	nameHeadlineOptions        = `StartSel=<mark>, StopSel=</mark>, HighlightAll=true`
+	descriptionHeadlineOptions = `StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5`
+
+	facetCategory = "category"
+	facetPrice    = "price"
+)
+
+// escapeHTML returns SQL escaping the text expr for HTML, so the only markup in a
+// headline is its <mark> tags. The text search parser reads each entity as one token
+// that is never matched or highlighted.
+func escapeHTML(expr string) string {
+	return `replace(replace(replace(replace(replace(` + expr +
+		`, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;')`
+}
+
+// searchQuery returns a productQuery with every filter applied except the facet named
+// by skip, plus the tsquery expression ("" when there is no text query). With locales
+// the query also joins each product's best translation as tr and matches its text
+// with the returned translated tsquery.
+func searchQuery(f models.SearchFilters, skip string) (q *productQuery, tsquery, translated string) {
+	q = newLiveProductQuery()
+
+	if f.Query != "" {
+		text := q.arg(f.Query)
+		tsquery = "websearch_to_tsquery('english', " + text + ")"
+		if len(f.Locales) > 0 {
+			translated = "websearch_to_tsquery('simple', " + text + ")"
+			q.from += translationJoin(q.arg(pq.Array(f.Locales)))
+			q.where = append(q.where, "(p.search_vector @@ "+tsquery+" OR tr.search_vector @@ "+translated+")")
+		} else {
+			q.where = append(q.where, "p.search_vector @@ "+tsquery)
+		}
+	}
+	if len(f.Categories) > 0 && skip != facetCategory {
+		q.where = append(q.where, "p.category IN "+categorySubtree("slug = ANY("+q.arg(pq.Array(f.Categories))+")"))
+	}
+	if skip != facetPrice {
+		if f.MinPrice != nil {
+			q.where = append(q.where, effectivePrice+" >= "+q.arg(*f.MinPrice))
+		}
+		if f.MaxPrice != nil {
+			q.where = append(q.where, effectivePrice+" <= "+q.arg(*f.MaxPrice))
+		}
+	}
+	keys := make([]string, 0, len(f.Attributes))
+	for key := range f.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		values := f.Attributes[key]
+		var alternatives []string
+		for _, v := range values {
+			for _, typed := range attributeValues(v) {
+				doc, _ := json.Marshal(map[string]interface{}{key: typed})
+				alternatives = append(alternatives, "p.attributes @> "+q.arg(string(doc))+"::jsonb")
+			}
+		}
+		q.where = append(q.where, "("+strings.Join(alternatives, " OR ")+")")
+	}
+	keys = keys[:0]
+	for key := range f.AttributeRanges {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		r := f.AttributeRanges[key]
+		// The CASE keeps non-numeric values away from the cast.
+		name := q.arg(key)
+		value := "(CASE WHEN jsonb_typeof(p.attributes -> " + name + "::text) = 'number' THEN (p.attributes ->> " + name + "::text)::numeric END)"
+		if r.Min != nil {
+			q.where = append(q.where, value+" >= "+q.arg(*r.Min))
+		}
+		if r.Max != nil {
+			q.where = append(q.where, value+" <= "+q.arg(*r.Max))
+		}
+	}
+	return q, tsquery, translated
+}
+
+// attributeValues returns the JSON values a query string attribute value matches: the
+// string itself and, when it parses as one, the number or boolean it spells.
+func attributeValues(v string) []interface{} {
+	values := []interface{}{v}
+	if n, err := strconv.ParseFloat(v, 64); err == nil && !math.IsInf(n, 0) && !math.IsNaN(n) {
+		values = append(values, n)
+	}
+	if v == "true" || v == "false" {
+		values = append(values, v == "true")
+	}
+	return values
+}
+
+// SearchProducts returns products matching the filters with facet counts. A text query
+// uses web search syntax ("quoted phrases", -exclusions, or) and orders results by
+// relevance unless opts.Sort asks for another order.
+func (s *PostgresStore) SearchProducts(f models.SearchFilters, opts models.ListOptions) (*models.SearchPage, error) {
+	q, tsquery, translated := searchQuery(f, "")
+	if tsquery != "" {
+		rank := "ts_rank(p.search_vector, " + tsquery + ")"
+		nameHeadline := "ts_headline('english', " + escapeHTML("p.name") + ", " + tsquery + ", '" + nameHeadlineOptions + "')"
+		descriptionHeadline := "ts_headline('english', " + escapeHTML("COALESCE(p.description, '')") + ", " + tsquery + ", '" + descriptionHeadlineOptions + "')"
+		if translated != "" {
+			// Rank by the better of the two matches and highlight the text the product is
+			// served with: its translation when it has one.
+			rank = "GREATEST(" + rank + ", COALESCE(ts_rank(tr.search_vector, " + translated + "), 0))"
+			nameHeadline = "CASE WHEN tr.name IS NULL THEN " + nameHeadline +
+				" ELSE ts_headline('simple', " + escapeHTML("tr.name") + ", " + translated + ", '" + nameHeadlineOptions + "') END"
+			descriptionHeadline = "CASE WHEN tr.name IS NULL THEN " + descriptionHeadline +
+				" ELSE ts_headline('simple', " + escapeHTML("tr.description") + ", " + translated + ", '" + descriptionHeadlineOptions + "') END"
+		}
+		q.columns = []string{rank, nameHeadline, descriptionHeadline}
+		q.defaultSort = sortSpec{keys: []sortKey{{rank, "real"}, {"p.id", "text"}}, desc: true}
+	}
+	if opts.Sort == models.SortRelevance {
+		opts.Sort = ""
+	}
+
+	result := &models.SearchPage{Products: []models.SearchHit{}}
+	var err error
+	result.NextCursor, result.Total, err = s.pageRows(q, opts, func(row rowScanner) error {
+		var hit models.SearchHit
+		if tsquery == "" {
+			if err := scanProduct(row, &hit.Product); err != nil {
+				return err
+			}
+		} else {
+			hit.Highlights = &models.SearchHighlights{}
+			if err := scanProduct(row, &hit.Product, &hit.Score, &hit.Highlights.Name, &hit.Highlights.Description); err != nil {
+				return err
+			}
+		}
+		result.Products = append(result.Products, hit)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Facets.Categories, err = s.categoryFacet(f); err != nil {
+		return nil, err
+	}
+	if result.Facets.PriceRanges, err = s.priceFacet(f); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *PostgresStore) categoryFacet(f models.SearchFilters) ([]models.FacetCount, error) {
+	q, _, _ := searchQuery(f, facetCategory)
+	rows, err := s.db.Query(`
+		SELECT COALESCE(p.category, ''), COUNT(*) FROM `+q.from+q.whereClause()+`
+		GROUP BY 1 ORDER BY 2 DESC, 1
+	`, q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []models.FacetCount{}
+	for rows.Next() {
+		var c models.FacetCount
+		if err := rows.Scan(&c.Value, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+func (s *PostgresStore) priceFacet(f models.SearchFilters) ([]models.PriceBucket, error) {
+	q, _, _ := searchQuery(f, facetPrice)
+	edges := q.arg(pq.Array(priceBucketEdges))
+	rows, err := s.db.Query(`
+		SELECT width_bucket(`+effectivePrice+`, `+edges+`::bigint[]), COUNT(*) FROM `+q.from+q.whereClause()+`
+		GROUP BY 1
+	`, q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := make([]models.PriceBucket, len(priceBucketEdges)+1)
+	for i := range buckets {
+		buckets[i].Min = money.Money{Currency: money.DefaultCurrency}
+		if i > 0 {
+			buckets[i].Min.Amount = priceBucketEdges[i-1]
+		}
+		if i < len(priceBucketEdges) {
+			buckets[i].Max = &money.Money{Amount: priceBucketEdges[i], Currency: money.DefaultCurrency}
+		}
+	}
+	for rows.Next() {
+		var bucket, count int
+		if err := rows.Scan(&bucket, &count); err != nil {
+			return nil, err
+		}
+		buckets[bucket].Count = count
+	}
+	return buckets, rows.Err()
+}
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/metalbear-co/metalmart/services/catalogue/models"
+)
+
+// suggestMinSimilarity is the lowest pg_trgm word similarity accepted as a typo
+// ("hoddie" scores about 0.57 against "Hoodie").
+const suggestMinSimilarity = 0.3
+
+// migrateSuggest enables pg_trgm and indexes product and category names for prefix
+// and similarity matching.
+func (s *PostgresStore) migrateSuggest() error {
+	query := `
+	CREATE EXTENSION IF NOT EXISTS pg_trgm;
+	CREATE INDEX IF NOT EXISTS idx_products_name_trgm ON products USING GIN (name gin_trgm_ops);
+	CREATE INDEX IF NOT EXISTS idx_categories_name_trgm ON categories USING GIN (name gin_trgm_ops);
+	`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+// likePrefix escapes q for LIKE and appends a wildcard.
+func likePrefix(q string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(q) + "%"
+}
+
+// Suggest completes a partial search query. Names where a word starts with q rank
+// first; the rest match by trigram word similarity so small typos still hit. With
+// locales, products are matched and returned by their best translated name.
+func (s *PostgresStore) Suggest(q string, limit int, locales []string) (*models.Suggestions, error) {
+	result := &models.Suggestions{
+		Query:      q,
+		Products:   []models.ProductSuggestion{},
+		Categories: []models.CategorySuggestion{},
+	}
+	prefix := likePrefix(q)
+
+	from, name := "products p", "p.name"
+	args := []interface{}{q, prefix, suggestMinSimilarity, limit}
+	if len(locales) > 0 {
+		from += translationJoin("$5")
+		name = "COALESCE(tr.name, p.name)"
+		args = append(args, pq.Array(locales))
+	}
+	rows, err := s.db.Query(`
+		SELECT p.id, `+name+`, COALESCE(p.category, ''), word_similarity($1, `+name+`) AS score
+		FROM `+from+`
+		WHERE `+liveProducts+`
+			AND (`+name+` ILIKE $2 OR `+name+` ILIKE '% ' || $2 OR word_similarity($1, `+name+`) >= $3)
+		ORDER BY (`+name+` ILIKE $2) DESC, (`+name+` ILIKE '% ' || $2) DESC, score DESC, 2
+		LIMIT $4
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p models.ProductSuggestion
+		if err := rows.Scan(&p.ID, &p.Name, &p.Category, &p.Score); err != nil {
+			return nil, err
+		}
+		result.Products = append(result.Products, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.Query(`
+		SELECT c.slug, c.name, word_similarity($1, c.name) AS score
+		FROM categories c
+		WHERE c.name ILIKE $2 OR c.name ILIKE '% ' || $2 OR c.slug ILIKE $2 OR word_similarity($1, c.name) >= $3
+		ORDER BY (c.name ILIKE $2) DESC, score DESC, c.name
+		LIMIT $4
+	`, q, prefix, suggestMinSimilarity, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c models.CategorySuggestion
+		if err := rows.Scan(&c.Slug, &c.Name, &c.Score); err != nil {
+			return nil, err
+		}
+		result.Categories = append(result.Categories, c)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,90 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/metalbear-co/metalmart/services/catalogue/models"
+)
+
+// ImportProducts upserts products by id in one transaction and returns the ids of the
+// products it created and updated. Products without an id get a generated one. New products
+// are appended to the display order and published unless a status is given; existing
+// ones keep their position, and their status when none is given. A dry run
+// performs the same writes and rolls them back.
+func (s *PostgresStore) ImportProducts(products []models.ProductInput, dryRun bool) (created, updated []string, err error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO products (id, name, description, price_cents, currency, image_url, category, attributes, status, publish_at, unpublish_at, display_order)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, COALESCE(NULLIF($9, ''), $12), $10, $11, (SELECT COALESCE(MAX(display_order), 0) + 1 FROM products))
+		ON CONFLICT (id) DO UPDATE SET
+			name = EXCLUDED.name, description = EXCLUDED.description,
+			price_cents = EXCLUDED.price_cents, currency = EXCLUDED.currency,
+			image_url = EXCLUDED.image_url, category = EXCLUDED.category, attributes = EXCLUDED.attributes,
+			status = COALESCE(NULLIF($9, ''), products.status), publish_at = EXCLUDED.publish_at, unpublish_at = EXCLUDED.unpublish_at,
+			updated_at = NOW()
+		RETURNING (xmax = 0)
+	`)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer stmt.Close()
+
+	for _, in := range products {
+		if in.ID == "" {
+			in.ID = uuid.New().String()
+		}
+		attributes, err := marshalAttributes(in.Attributes)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var inserted bool
+		err = stmt.QueryRow(in.ID, in.Name, in.Description, in.Price.Amount, in.Price.Currency, in.ImageURL, in.Category, attributes, in.Status, in.PublishAt, in.UnpublishAt, models.StatusPublished).Scan(&inserted)
+		if err != nil {
+			return nil, nil, fmt.Errorf("product %s: %w", in.ID, productWriteError(err))
+		}
+		if err := recordListPrice(tx, in.ID); err != nil {
+			return nil, nil, fmt.Errorf("product %s: %w", in.ID, err)
+		}
+		if err := syncPrimaryMedia(tx, in.ID); err != nil {
+			return nil, nil, fmt.Errorf("product %s: %w", in.ID, err)
+		}
+		if inserted {
+			created = append(created, in.ID)
+		} else {
+			updated = append(updated, in.ID)
+		}
+	}
+
+	if dryRun {
+		return created, updated, nil
+	}
+	return created, updated, tx.Commit()
+}
+
+// EachProduct calls fn for every product ordered by id, streaming rows from the
+// database rather than loading the catalogue into memory.
+func (s *PostgresStore) EachProduct(fn func(models.Product) error) error {
+	rows, err := s.db.Query(`SELECT ` + productColumns + ` FROM ` + productsFrom + ` ORDER BY p.id`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.Product
+		if err := scanProduct(rows, &p); err != nil {
+			return err
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+	"github.com/metalbear-co/metalmart/services/catalogue/models"
+)
+
+// ErrTranslationNotFound is returned when a product has no translation for a locale.
+var ErrTranslationNotFound = errors.New("translation not found")
+
+// migrateTranslations stores per-locale product names and descriptions. Translations
+// are indexed with the 'simple' text search configuration since their language varies.
+func (s *PostgresStore) migrateTranslations() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS product_translations (
+		product_id VARCHAR(50) NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+		locale VARCHAR(35) NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		search_vector tsvector GENERATED ALWAYS AS (
+			setweight(to_tsvector('simple', name), 'A') ||
+			setweight(to_tsvector('simple', description), 'B')
+		) STORED,
+		PRIMARY KEY (product_id, locale)
+	);
+	CREATE INDEX IF NOT EXISTS idx_product_translations_search_vector ON product_translations USING GIN(search_vector);
+	CREATE INDEX IF NOT EXISTS idx_product_translations_name_trgm ON product_translations USING GIN (name gin_trgm_ops);
+	`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+// translationJoin picks each product's best translation among the locale candidates
+// bound to $L, aliased tr. Products without one get NULL columns.
+func translationJoin(locales string) string {
+	return ` LEFT JOIN LATERAL (
+		SELECT t.locale, t.name, t.description, t.search_vector FROM product_translations t
+		WHERE t.product_id = p.id AND t.locale = ANY(` + locales + `::text[])
+		ORDER BY array_position(` + locales + `::text[], t.locale::text) LIMIT 1
+	) tr ON true`
+}
+
+func (s *PostgresStore) ListTranslations(productID string) ([]models.Translation, error) {
+	if _, err := s.GetProduct(productID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`SELECT locale, name, description, updated_at FROM product_translations WHERE product_id = $1 ORDER BY locale`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	translations := []models.Translation{}
+	for rows.Next() {
+		var t models.Translation
+		if err := rows.Scan(&t.Locale, &t.Name, &t.Description, &t.UpdatedAt); err != nil {
+			return nil, err
+		}
+		translations = append(translations, t)
+	}
+	return translations, rows.Err()
+}
+
+// SetTranslation creates or replaces the translation of a product for locale.
+func (s *PostgresStore) SetTranslation(productID, locale string, in models.TranslationInput) (*models.Translation, error) {
+	t := models.Translation{Locale: locale, Name: in.Name, Description: in.Description}
+	err := s.db.QueryRow(`
+		INSERT INTO product_translations (product_id, locale, name, description) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (product_id, locale) DO UPDATE SET
+			name = EXCLUDED.name, description = EXCLUDED.description, updated_at = NOW()
+		RETURNING updated_at
+	`, productID, locale, in.Name, in.Description).Scan(&t.UpdatedAt)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, s.touchProduct(productID)
+}
+
+func (s *PostgresStore) DeleteTranslation(productID, locale string) error {
+	res, err := s.db.Exec(`DELETE FROM product_translations WHERE product_id = $1 AND locale = $2`, productID, locale)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrTranslationNotFound
+	}
+	return s.touchProduct(productID)
+}
+
+// Translate replaces the name and description of products with their translation in
+// the first of locales that has one. Products without a translation keep the
+// default-locale content.
+func (s *PostgresStore) Translate(locales []string, products ...*models.Product) error {
+	if len(locales) == 0 || len(products) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(products))
+	for i, p := range products {
+		ids[i] = p.ID
+	}
+	rows, err := s.db.Query(`
+		SELECT DISTINCT ON (product_id) product_id, locale, name, description
+		FROM product_translations
+		WHERE product_id = ANY($1) AND locale = ANY($2::text[])
+		ORDER BY product_id, array_position($2::text[], locale::text)
+	`, pq.Array(ids), pq.Array(locales))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	translations := map[string]models.Translation{}
+	for rows.Next() {
+		var id string
+		var t models.Translation
+		if err := rows.Scan(&id, &t.Locale, &t.Name, &t.Description); err != nil {
+			return err
+		}
+		translations[id] = t
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, p := range products {
+		if t, ok := translations[p.ID]; ok {
+			p.Name, p.Description, p.Locale = t.Name, t.Description, t.Locale
+		}
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/metalbear-co/metalmart/pkg/money"
+	"github.com/metalbear-co/metalmart/services/catalogue/models"
+)
+
+var ErrDuplicateSKU = errors.New("variant with this sku already exists")
+
+func (s *PostgresStore) migrateVariants() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS product_variants (
+		id VARCHAR(50) PRIMARY KEY,
+		product_id VARCHAR(50) NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+		sku VARCHAR(64) NOT NULL UNIQUE,
+		attributes JSONB NOT NULL DEFAULT '{}',
+		price_override_cents BIGINT,
+		image_url VARCHAR(500),
+		position INT NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_product_variants_product_id ON product_variants(product_id);
+
+	DO $$
+	BEGIN
+		IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'product_variants' AND column_name = 'price_override') THEN
+			ALTER TABLE product_variants ADD COLUMN IF NOT EXISTS price_override_cents BIGINT;
+			UPDATE product_variants SET price_override_cents = ROUND(price_override * 100) WHERE price_override IS NOT NULL;
+			ALTER TABLE product_variants DROP COLUMN price_override;
+		END IF;
+	END $$;
+
+	-- An override keeps the currency it was set in, and the product's currency cannot
+	-- change under it.
+	ALTER TABLE product_variants ADD COLUMN IF NOT EXISTS price_override_currency CHAR(3);
+	UPDATE product_variants v SET price_override_currency = p.currency
+	FROM products p
+	WHERE p.id = v.product_id AND v.price_override_cents IS NOT NULL AND v.price_override_currency IS NULL;
+
+	DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'products_id_currency_key') THEN
+			ALTER TABLE products ADD CONSTRAINT products_id_currency_key UNIQUE (id, currency);
+		END IF;
+		IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'product_variants_override_currency_fkey') THEN
+			ALTER TABLE product_variants ADD CONSTRAINT product_variants_override_currency_fkey
+				FOREIGN KEY (product_id, price_override_currency) REFERENCES products(id, currency)
+				ON UPDATE RESTRICT ON DELETE CASCADE;
+		END IF;
+		IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'product_variants_override_currency_check') THEN
+			ALTER TABLE product_variants ADD CONSTRAINT product_variants_override_currency_check
+				CHECK ((price_override_cents IS NULL) = (price_override_currency IS NULL));
+		END IF;
+	END $$;
+	`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+const variantColumns = `v.id, v.product_id, v.sku, v.attributes, v.price_override_cents, COALESCE(v.price_override_currency, ''), ` + variantPrice + `, p.currency, ` + variantCompareAt + `, COALESCE(v.image_url, ''), v.position, v.created_at`
+
+func scanVariant(row rowScanner, v *models.Variant) error {
+	var attributes []byte
+	var override, compareAt sql.NullInt64
+	var overrideCurrency string
+	err := row.Scan(&v.ID, &v.ProductID, &v.SKU, &attributes, &override, &overrideCurrency, &v.Price.Amount, &v.Price.Currency, &compareAt, &v.ImageURL, &v.Position, &v.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if override.Valid {
+		v.PriceOverride = &money.Money{Amount: override.Int64, Currency: overrideCurrency}
+	}
+	if compareAt.Valid {
+		v.CompareAt = &money.Money{Amount: compareAt.Int64, Currency: v.Price.Currency}
+	}
+	return json.Unmarshal(attributes, &v.Attributes)
+}
+
+func (s *PostgresStore) ListVariants(productID string) ([]models.Variant, error) {
+	rows, err := s.db.Query(`
+		SELECT `+variantColumns+`
+		FROM product_variants v
+		JOIN products p ON p.id = v.product_id
+		`+scheduledPriceJoin+`
+		WHERE v.product_id = $1
+		ORDER BY v.position, v.id
+	`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	variants := []models.Variant{}
+	for rows.Next() {
+		var v models.Variant
+		if err := scanVariant(rows, &v); err != nil {
+			return nil, err
+		}
+		variants = append(variants, v)
+	}
+	return variants, rows.Err()
+}
+
+func (s *PostgresStore) GetVariant(productID, variantID string) (*models.Variant, error) {
+	var v models.Variant
+	err := scanVariant(s.db.QueryRow(`
+		SELECT `+variantColumns+`
+		FROM product_variants v
+		JOIN products p ON p.id = v.product_id
+		`+scheduledPriceJoin+`
+		WHERE v.product_id = $1 AND v.id = $2
+	`, productID, variantID), &v)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (s *PostgresStore) CreateVariant(productID string, in models.VariantInput) (*models.Variant, error) {
+	if in.ID == "" {
+		in.ID = uuid.New().String()
+	}
+	attributes, err := json.Marshal(in.Attributes)
+	if err != nil {
+		return nil, err
+	}
+	overrideCents, overrideCurrency, err := s.priceOverride(productID, in.PriceOverride)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO product_variants (id, product_id, sku, attributes, price_override_cents, price_override_currency, image_url, position)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8)
+	`, in.ID, productID, in.SKU, attributes, overrideCents, overrideCurrency, in.ImageURL, in.Position)
+	if err != nil {
+		return nil, variantWriteError(err)
+	}
+	if err := s.touchProduct(productID); err != nil {
+		return nil, err
+	}
+	return s.GetVariant(productID, in.ID)
+}
+
+func (s *PostgresStore) UpdateVariant(productID, variantID string, in models.VariantInput) (*models.Variant, error) {
+	attributes, err := json.Marshal(in.Attributes)
+	if err != nil {
+		return nil, err
+	}
+	overrideCents, overrideCurrency, err := s.priceOverride(productID, in.PriceOverride)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.db.Exec(`
+		UPDATE product_variants
+		SET sku = $3, attributes = $4, price_override_cents = $5, price_override_currency = $6, image_url = NULLIF($7, ''), position = $8
+		WHERE product_id = $1 AND id = $2
+	`, productID, variantID, in.SKU, attributes, overrideCents, overrideCurrency, in.ImageURL, in.Position)
+	if err != nil {
+		return nil, variantWriteError(err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrNotFound
+	}
+	if err := s.touchProduct(productID); err != nil {
+		return nil, err
+	}
+	return s.GetVariant(productID, variantID)
+}
+
+// priceOverride checks that a variant price override is in the product's currency and
+// returns its amount and currency, both null when there is no override.
+func (s *PostgresStore) priceOverride(productID string, override *money.Money) (cents sql.NullInt64, currency sql.NullString, err error) {
+	err = s.db.QueryRow(`SELECT currency FROM products WHERE id = $1`, productID).Scan(&currency)
+	if errors.Is(err, sql.ErrNoRows) {
+		return cents, currency, ErrNotFound
+	}
+	if err != nil || override == nil {
+		return sql.NullInt64{}, sql.NullString{}, err
+	}
+	if override.Currency != "" && override.Currency != currency.String {
+		return cents, currency, fmt.Errorf("%w: price_override must be in the product currency %s", models.ErrValidation, currency.String)
+	}
+	return sql.NullInt64{Int64: override.Amount, Valid: true}, currency, nil
+}
+
+func (s *PostgresStore) DeleteVariant(productID, variantID string) error {
+	res, err := s.db.Exec(`DELETE FROM product_variants WHERE product_id = $1 AND id = $2`, productID, variantID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return s.touchProduct(productID)
+}
+
+// variantWriteError maps unique violations to ErrDuplicateSKU (or ErrDuplicateID when the
+// variant id is taken) and a missing parent product to ErrNotFound.
+func variantWriteError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+	switch {
+	case pqErr.Code == "23505" && pqErr.Constraint == "product_variants_pkey":
+		return ErrDuplicateID
+	case pqErr.Code == "23505":
+		return ErrDuplicateSKU
+	case pqErr.Code == "23503" && pqErr.Constraint == "product_variants_override_currency_fkey":
+		return fmt.Errorf("%w: price_override must be in the product currency", models.ErrValidation)
+	case pqErr.Code == "23503":
+		return ErrNotFound
+	}
+	return err
+}
@@ -2,4 +2,9 @@ module github.com/metalbear-co/metalmart/services/checkout
 
 go 1.23
 
-require github.com/gorilla/mux v1.8.1
+require (
+	github.com/gorilla/mux v1.8.1
+	github.com/metalbear-co/metalmart/pkg/money v0.0.0
+)
+
+replace github.com/metalbear-co/metalmart/pkg/money => ../../pkg/money
@@ -2,17 +2,34 @@ package handlers
 
 import (
 	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/metalbear-co/metalmart/pkg/money"
 	"github.com/metalbear-co/metalmart/services/checkout/models"
 )
 
+// inventoryAttempts is how many times an inventory request is sent before giving up.
+const inventoryAttempts = 2
+
+const (
+	// defaultReservationTTL bounds confirm retries when inventory does not say when a
+	// reservation expires; it matches inventory's default RESERVATION_TTL.
+	defaultReservationTTL = 15 * time.Minute
+	confirmRetryDelay     = 2 * time.Second
+	maxConfirmRetryDelay  = time.Minute
+)
+
 type Handler struct {
 	inventoryURL string
 	orderURL     string
@@ -23,7 +40,7 @@ func NewHandler(inventoryURL, orderURL string) *Handler {
 	return &Handler{
 		inventoryURL: inventoryURL,
 		orderURL:     orderURL,
-		httpClient:   &http.Client{},
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
 	}
 }
 
@@ -42,12 +59,34 @@ func (h *Handler) ProcessCheckout(w http.ResponseWriter, r *http.Request) {
 		respondError(w, "Missing required fields", http.StatusBadRequest)
 		return
 	}
+	if req.Currency == "" {
+		req.Currency = money.AcceptedCurrency(r.Header.Get("Accept-Currency"))
+	}
+	if strings.TrimSpace(req.Currency) == "" {
+		req.Currency = req.Items[0].Price.Currency
+	}
+	req.Currency = money.NormalizeCurrency(req.Currency)
+	if !money.ValidCurrency(req.Currency) {
+		respondError(w, "Currency must be a three-letter ISO 4217 code", http.StatusBadRequest)
+		return
+	}
+	for i := range req.Items {
+		req.Items[i].Price.Currency = money.NormalizeCurrency(req.Items[i].Price.Currency)
+		if req.Items[i].Price.Currency != req.Currency {
+			respondError(w, fmt.Sprintf("All items must be priced in %s", req.Currency), http.StatusBadRequest)
+			return
+		}
+	}
 
 	// Step 1: Reserve inventory
-	reserveReq := models.ReserveRequest{Items: make([]models.ReserveItem, len(req.Items))}
+	reserveReq := models.ReserveRequest{
+		Items:           make([]models.ReserveItem, len(req.Items)),
+		ShippingCountry: req.ShippingAddress.CountryCode(),
+	}
 	for i, item := range req.Items {
 		reserveReq.Items[i] = models.ReserveItem{
 			ProductID: item.ProductID,
+			VariantID: item.VariantID,
 			Quantity:  item.Quantity,
 		}
 	}
@@ -69,10 +108,12 @@ func (h *Handler) ProcessCheckout(w http.ResponseWriter, r *http.Request) {
 		CustomerName:    req.CustomerName,
 		ShippingAddress: req.ShippingAddress,
 		ReservationID:   reserveResp.ReservationID,
+		Currency:        req.Currency,
 	}
 	for _, item := range req.Items {
 		orderReq.Items = append(orderReq.Items, models.OrderItem{
 			ProductID:   item.ProductID,
+			VariantID:   item.VariantID,
 			ProductName: item.ProductName,
 			Quantity:    item.Quantity,
 			Price:       item.Price,
@@ -81,7 +122,9 @@ func (h *Handler) ProcessCheckout(w http.ResponseWriter, r *http.Request) {
 
 	orderResp, err := h.createOrder(orderReq)
 	if err != nil {
-		h.releaseInventory(reserveResp.ReservationID)
+		if err := h.releaseInventory(reserveResp.ReservationID); err != nil {
+			log.Printf("Warning: Failed to release reservation %s: %v", reserveResp.ReservationID, err)
+		}
 		respondError(w, fmt.Sprintf("Failed to create order: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -89,10 +132,11 @@ func (h *Handler) ProcessCheckout(w http.ResponseWriter, r *http.Request) {
 		log.Printf("[demo] db proof created_order_number=%s", orderResp.OrderNumber)
 	}
 
-	// Step 3: Confirm inventory reservation
-	if err := h.confirmInventory(reserveResp.ReservationID); err != nil {
-		// Log but don't fail - order is already created
-		fmt.Printf("Warning: Failed to confirm inventory: %v\n", err)
+	// Step 3: Confirm inventory reservation. The order is already created, so a failure
+	// is retried in the background rather than reported to the customer.
+	if err := h.confirmInventory(reserveResp.ReservationID, orderResp.ID); err != nil {
+		log.Printf("Warning: Failed to confirm reservation %s for order %s, retrying: %v", reserveResp.ReservationID, orderResp.ID, err)
+		go h.retryConfirm(reserveResp.ReservationID, orderResp.ID, reserveResp.ExpiresAt)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -101,7 +145,7 @@ func (h *Handler) ProcessCheckout(w http.ResponseWriter, r *http.Request) {
 		OrderID:       orderResp.ID,
 		OrderNumber:   orderResp.OrderNumber,
 		TrackingToken: orderResp.TrackingToken,
-		TotalAmount:   orderResp.TotalAmount,
+		TotalAmount:   &orderResp.TotalAmount,
 	})
 }
 
@@ -117,7 +161,11 @@ func (h *Handler) ValidateCart(w http.ResponseWriter, r *http.Request) {
 
 	// Check inventory for each item
 	for _, item := range req.Items {
-		resp, err := h.httpClient.Get(fmt.Sprintf("%s/api/inventory/%s", h.inventoryURL, item.ProductID))
+		inventoryURL := fmt.Sprintf("%s/api/inventory/%s", h.inventoryURL, url.PathEscape(item.ProductID))
+		if item.VariantID != "" {
+			inventoryURL += "?variant_id=" + url.QueryEscape(item.VariantID)
+		}
+		resp, err := h.httpClient.Get(inventoryURL)
 		if err != nil {
 			w.Header().Set("Content-Type", "application/json")
 			json.NewEncoder(w).Encode(models.ValidateCartResponse{
@@ -161,51 +209,105 @@ func (h *Handler) ValidateCart(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) reserveInventory(req models.ReserveRequest) (*models.ReserveResponse, error) {
-	body, _ := json.Marshal(req)
-	resp, err := h.httpClient.Post(
-		fmt.Sprintf("%s/api/inventory/reserve", h.inventoryURL),
-		"application/json",
-		bytes.NewBuffer(body),
-	)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
 	var reserveResp models.ReserveResponse
-	if err := json.NewDecoder(resp.Body).Decode(&reserveResp); err != nil {
+	if err := h.postInventory("reserve", newIdempotencyKey(), req, &reserveResp); err != nil {
 		return nil, err
 	}
-
 	return &reserveResp, nil
 }
 
+// releaseInventory and confirmInventory key their requests by reservation id: each
+// reservation is released or confirmed at most once.
 func (h *Handler) releaseInventory(reservationID string) error {
-	body, _ := json.Marshal(map[string]string{"reservation_id": reservationID})
-	resp, err := h.httpClient.Post(
-		fmt.Sprintf("%s/api/inventory/release", h.inventoryURL),
-		"application/json",
-		bytes.NewBuffer(body),
-	)
-	if err != nil {
-		return err
+	return h.postInventory("release", reservationID, map[string]string{"reservation_id": reservationID}, nil)
+}
+
+// confirmInventory confirms a reservation for the order it was made for, which
+// inventory records as the reference of the stock taken out.
+func (h *Handler) confirmInventory(reservationID, orderID string) error {
+	return h.postInventory("confirm", reservationID, map[string]string{"reservation_id": reservationID, "order_id": orderID}, nil)
+}
+
+// retryConfirm keeps confirming a reservation with backoff until inventory accepts or
+// refuses it, or it expires and the reaper puts its stock back on sale. An order whose
+// reservation was never confirmed is logged for reconciliation.
+func (h *Handler) retryConfirm(reservationID, orderID string, expiresAt *time.Time) {
+	deadline := time.Now().Add(defaultReservationTTL)
+	if expiresAt != nil {
+		deadline = *expiresAt
 	}
-	defer resp.Body.Close()
-	return nil
+	delay := confirmRetryDelay
+	for time.Now().Add(delay).Before(deadline) {
+		time.Sleep(delay)
+		err := h.confirmInventory(reservationID, orderID)
+		if err == nil {
+			log.Printf("Reservation %s for order %s confirmed after retrying", reservationID, orderID)
+			return
+		}
+		var statusErr *inventoryStatusError
+		if errors.As(err, &statusErr) && statusErr.status/100 == 4 {
+			log.Printf("RECONCILE order=%s reservation=%s: inventory refused confirm: %v", orderID, reservationID, err)
+			return
+		}
+		log.Printf("Confirm of reservation %s for order %s failed, retrying: %v", reservationID, orderID, err)
+		delay = min(2*delay, maxConfirmRetryDelay)
+	}
+	log.Printf("RECONCILE order=%s reservation=%s: reservation expires before it could be confirmed", orderID, reservationID)
 }
 
-func (h *Handler) confirmInventory(reservationID string) error {
-	body, _ := json.Marshal(map[string]string{"reservation_id": reservationID})
-	resp, err := h.httpClient.Post(
-		fmt.Sprintf("%s/api/inventory/confirm", h.inventoryURL),
-		"application/json",
-		bytes.NewBuffer(body),
-	)
+// inventoryStatusError is a non-2xx response from inventory.
+type inventoryStatusError struct {
+	action string
+	status int
+	body   string
+}
+
+func (e *inventoryStatusError) Error() string {
+	return fmt.Sprintf("inventory %s returned %d: %s", e.action, e.status, e.body)
+}
+
+// postInventory sends an inventory request with an Idempotency-Key, retrying once when
+// the request fails in transit; the key makes inventory apply it at most once. The
+// response body is decoded into out when out is not nil. A non-2xx response is an
+// error, except a 409 to a request with out, whose body reports the refusal.
+func (h *Handler) postInventory(action, key string, payload, out interface{}) error {
+	body, _ := json.Marshal(payload)
+	var resp *http.Response
+	var err error
+	for attempt := 1; attempt <= inventoryAttempts; attempt++ {
+		var req *http.Request
+		req, err = http.NewRequest(http.MethodPost, fmt.Sprintf("%s/api/inventory/%s", h.inventoryURL, action), bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", key)
+		req.Header.Set("X-Actor", "checkout")
+		if resp, err = h.httpClient.Do(req); err == nil {
+			break
+		}
+		log.Printf("Inventory %s attempt %d failed: %v", action, attempt, err)
+	}
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
-	return nil
+
+	if resp.StatusCode/100 != 2 && (out == nil || resp.StatusCode != http.StatusConflict) {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &inventoryStatusError{action: action, status: resp.StatusCode, body: strings.TrimSpace(string(body))}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// newIdempotencyKey returns a random key identifying one checkout's reservation.
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
 }
 
 func (h *Handler) createOrder(req models.CreateOrderRequest) (*models.CreateOrderResponse, error) {
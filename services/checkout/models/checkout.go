@@ -1,10 +1,20 @@
 package models
 
+import (
+	"strings"
+	"time"
+
+	"github.com/metalbear-co/metalmart/pkg/money"
+)
+
 type CheckoutRequest struct {
 	CustomerEmail   string          `json:"customer_email"`
 	CustomerName    string          `json:"customer_name"`
 	ShippingAddress ShippingAddress `json:"shipping_address"`
 	Items           []CartItem      `json:"items"`
+	// Currency the cart is priced in; defaults to the Accept-Currency header, then to
+	// the currency of the first item.
+	Currency string `json:"currency,omitempty"`
 }
 
 type ShippingAddress struct {
@@ -15,20 +25,32 @@ type ShippingAddress struct {
 	Country string `json:"country"`
 }
 
+// CountryCode returns the address's country as an ISO 3166 alpha-2 code. The country
+// may be entered as an alpha-2 or alpha-3 code or as its English name; anything else
+// gives "".
+func (a ShippingAddress) CountryCode() string {
+	country := strings.TrimSpace(a.Country)
+	if code, ok := countryCodes[strings.ToUpper(country)]; ok {
+		return code
+	}
+	return countryCodes[strings.ToLower(strings.Join(strings.Fields(country), " "))]
+}
+
 type CartItem struct {
-	ProductID   string  `json:"product_id"`
-	ProductName string  `json:"product_name"`
-	Quantity    int     `json:"quantity"`
-	Price       float64 `json:"price"`
+	ProductID   string      `json:"product_id"`
+	VariantID   string      `json:"variant_id,omitempty"`
+	ProductName string      `json:"product_name"`
+	Quantity    int         `json:"quantity"`
+	Price       money.Money `json:"price"`
 }
 
 type CheckoutResponse struct {
-	Success       bool   `json:"success"`
-	OrderID       string `json:"order_id,omitempty"`
-	OrderNumber   string `json:"order_number,omitempty"`
-	TrackingToken string `json:"tracking_token,omitempty"`
-	Message       string `json:"message,omitempty"`
-	TotalAmount   float64 `json:"total_amount,omitempty"`
+	Success       bool         `json:"success"`
+	OrderID       string       `json:"order_id,omitempty"`
+	OrderNumber   string       `json:"order_number,omitempty"`
+	TrackingToken string       `json:"tracking_token,omitempty"`
+	Message       string       `json:"message,omitempty"`
+	TotalAmount   *money.Money `json:"total_amount,omitempty"`
 }
 
 type ValidateCartRequest struct {
@@ -41,19 +63,24 @@ type ValidateCartResponse struct {
 }
 
 // Internal types for service communication
+// ReserveRequest is sent to inventory. ShippingCountry, when set, makes inventory
+// prefer warehouses in that country.
 type ReserveRequest struct {
-	Items []ReserveItem `json:"items"`
+	Items           []ReserveItem `json:"items"`
+	ShippingCountry string        `json:"shipping_country,omitempty"`
 }
 
 type ReserveItem struct {
 	ProductID string `json:"product_id"`
+	VariantID string `json:"variant_id,omitempty"`
 	Quantity  int    `json:"quantity"`
 }
 
 type ReserveResponse struct {
-	ReservationID string `json:"reservation_id"`
-	Success       bool   `json:"success"`
-	Message       string `json:"message,omitempty"`
+	ReservationID string     `json:"reservation_id"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	Success       bool       `json:"success"`
+	Message       string     `json:"message,omitempty"`
 }
 
 type CreateOrderRequest struct {
@@ -62,19 +89,21 @@ type CreateOrderRequest struct {
 	ShippingAddress ShippingAddress `json:"shipping_address"`
 	Items           []OrderItem     `json:"items"`
 	ReservationID   string          `json:"reservation_id"`
+	Currency        string          `json:"currency"`
 }
 
 type OrderItem struct {
-	ProductID   string  `json:"product_id"`
-	ProductName string  `json:"product_name"`
-	Quantity    int     `json:"quantity"`
-	Price       float64 `json:"price"`
+	ProductID   string      `json:"product_id"`
+	VariantID   string      `json:"variant_id,omitempty"`
+	ProductName string      `json:"product_name"`
+	Quantity    int         `json:"quantity"`
+	Price       money.Money `json:"price"`
 }
 
 type CreateOrderResponse struct {
-	ID            string  `json:"id"`
-	OrderNumber   string  `json:"order_number"`
-	TrackingToken string  `json:"tracking_token"`
-	TotalAmount   float64 `json:"total_amount"`
-	Status        string  `json:"status"`
+	ID            string      `json:"id"`
+	OrderNumber   string      `json:"order_number"`
+	TrackingToken string      `json:"tracking_token"`
+	TotalAmount   money.Money `json:"total_amount"`
+	Status        string      `json:"status"`
 }
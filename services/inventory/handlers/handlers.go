@@ -2,9 +2,13 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/metalbear-co/metalmart/services/inventory/models"
@@ -12,11 +16,14 @@ import (
 )
 
 type Handler struct {
-	store      *store.PostgresStore
-	dbSource   string
+	store          *store.PostgresStore
+	dbSource       string
+	reservationTTL time.Duration
 }
 
-func NewHandler(s *store.PostgresStore, dbSource string) *Handler {
+// NewHandler returns a Handler whose reservations expire after reservationTTL unless
+// checkout confirms or releases them first.
+func NewHandler(s *store.PostgresStore, dbSource string, reservationTTL time.Duration) *Handler {
 	if dbSource == "" {
 		if os.Getenv("MIRRORD_DB_BRANCH") == "true" {
 			dbSource = "mirrord-db-branch"
@@ -24,7 +31,7 @@ func NewHandler(s *store.PostgresStore, dbSource string) *Handler {
 			dbSource = "cluster"
 		}
 	}
-	return &Handler{store: s, dbSource: dbSource}
+	return &Handler{store: s, dbSource: dbSource, reservationTTL: reservationTTL}
 }
 
 func (h *Handler) setDatabaseSourceHeader(w http.ResponseWriter) {
@@ -34,10 +41,11 @@ func (h *Handler) setDatabaseSourceHeader(w http.ResponseWriter) {
 func (h *Handler) GetInventory(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	productID := vars["productId"]
+	variantID := r.URL.Query().Get("variant_id")
 
 	h.setDatabaseSourceHeader(w)
 
-	inv, err := h.store.GetInventory(productID)
+	inv, err := h.store.GetInventory(productID, variantID)
 	if err != nil {
 		http.Error(w, "Inventory not found", http.StatusNotFound)
 		return
@@ -60,8 +68,31 @@ func (h *Handler) Reserve(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	req.Normalize()
+	if err := req.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	key, ok := idempotencyKey(w, r, models.OperationReserve, req)
+	if !ok {
+		return
+	}
+	actor, ok := requestActor(w, r)
+	if !ok {
+		return
+	}
 
-	reservationID, err := h.store.Reserve(req.Items)
+	resp, replay, err := h.store.Reserve(req, h.reservationTTL, key, actor)
+	if errors.Is(err, store.ErrIdempotencyConflict) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if replay != nil {
+		log.Printf("[%s] Reserve replayed idempotency_key=%s", h.dbSource, key.Key)
+		writeReplay(w, replay)
+		return
+	}
 	if err != nil {
 		log.Printf("[%s] Reserve FAILED items=%v: %v", h.dbSource, req.Items, err)
 		w.Header().Set("Content-Type", "application/json")
@@ -73,12 +104,9 @@ func (h *Handler) Reserve(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("[%s] Reserve OK items=%v → reservation_id=%s", h.dbSource, req.Items, reservationID)
+	log.Printf("[%s] Reserve OK items=%v strategy=%s → reservation_id=%s allocations=%v expires_at=%s", h.dbSource, req.Items, req.Strategy, resp.ReservationID, resp.Allocations, resp.ExpiresAt.Format(time.RFC3339))
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(models.ReserveResponse{
-		ReservationID: reservationID,
-		Success:       true,
-	})
+	json.NewEncoder(w).Encode(resp)
 }
 
 func (h *Handler) Release(w http.ResponseWriter, r *http.Request) {
@@ -89,15 +117,41 @@ func (h *Handler) Release(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.store.Release(req.ReservationID); err != nil {
+	key, ok := idempotencyKey(w, r, models.OperationRelease, req)
+	if !ok {
+		return
+	}
+	actor, ok := requestActor(w, r)
+	if !ok {
+		return
+	}
+
+	replay, err := h.store.Release(req.ReservationID, key, actor)
+	if errors.Is(err, store.ErrIdempotencyConflict) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if replay != nil {
+		log.Printf("[%s] Release replayed reservation_id=%s idempotency_key=%s", h.dbSource, req.ReservationID, key.Key)
+		writeReplay(w, replay)
+		return
+	}
+	if errors.Is(err, store.ErrReservationNotFound) {
 		log.Printf("[%s] Release FAILED reservation_id=%s: %v", h.dbSource, req.ReservationID, err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.OperationResult{Success: false, Message: err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("[%s] Release FAILED reservation_id=%s: %v", h.dbSource, req.ReservationID, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	log.Printf("[%s] Release OK reservation_id=%s (reverted reserved qty)", h.dbSource, req.ReservationID)
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.OperationResult{Success: true})
 }
 
 func (h *Handler) Confirm(w http.ResponseWriter, r *http.Request) {
@@ -108,15 +162,41 @@ func (h *Handler) Confirm(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.store.Confirm(req.ReservationID); err != nil {
+	key, ok := idempotencyKey(w, r, models.OperationConfirm, req)
+	if !ok {
+		return
+	}
+	actor, ok := requestActor(w, r)
+	if !ok {
+		return
+	}
+
+	replay, err := h.store.Confirm(req, key, actor)
+	if errors.Is(err, store.ErrIdempotencyConflict) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if replay != nil {
+		log.Printf("[%s] Confirm replayed reservation_id=%s idempotency_key=%s", h.dbSource, req.ReservationID, key.Key)
+		writeReplay(w, replay)
+		return
+	}
+	if errors.Is(err, store.ErrReservationNotFound) {
 		log.Printf("[%s] Confirm FAILED reservation_id=%s: %v", h.dbSource, req.ReservationID, err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.OperationResult{Success: false, Message: err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("[%s] Confirm FAILED reservation_id=%s: %v", h.dbSource, req.ReservationID, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	log.Printf("[%s] Confirm OK reservation_id=%s (stock reduced on branch)", h.dbSource, req.ReservationID)
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.OperationResult{Success: true})
 }
 
 func (h *Handler) InitInventory(w http.ResponseWriter, r *http.Request) {
@@ -127,13 +207,70 @@ func (h *Handler) InitInventory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.store.InitInventory(req.ProductID, req.Quantity); err != nil {
-		log.Printf("[%s] InitInventory FAILED product_id=%s qty=%d: %v", h.dbSource, req.ProductID, req.Quantity, err)
+	if req.LocationID == "" {
+		req.LocationID = models.DefaultLocationID
+	}
+	if req.Quantity < 0 {
+		http.Error(w, "quantity must not be negative", http.StatusBadRequest)
+		return
+	}
+	if req.Reason = strings.TrimSpace(req.Reason); len(req.Reason) > 500 {
+		http.Error(w, "reason must be at most 500 characters", http.StatusBadRequest)
+		return
+	}
+	actor, ok := requestActor(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.store.InitInventory(req, actor)
+	if errors.Is(err, store.ErrLocationNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[%s] InitInventory FAILED product_id=%s variant_id=%s location_id=%s qty=%d: %v", h.dbSource, req.ProductID, req.VariantID, req.LocationID, req.Quantity, err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("[%s] InitInventory OK product_id=%s qty=%d", h.dbSource, req.ProductID, req.Quantity)
+	log.Printf("[%s] InitInventory OK product_id=%s variant_id=%s location_id=%s qty=%d", h.dbSource, req.ProductID, req.VariantID, req.LocationID, req.Quantity)
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]bool{"success": true})
 }
+
+// idempotencyKey reads the optional Idempotency-Key header for a request to operation,
+// answering 400 when it is malformed.
+func idempotencyKey(w http.ResponseWriter, r *http.Request, operation string, req interface{}) (*models.IdempotencyKey, bool) {
+	header := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+	if len(header) > models.MaxIdempotencyKeyLength {
+		http.Error(w, fmt.Sprintf("Idempotency-Key must be at most %d characters", models.MaxIdempotencyKeyLength), http.StatusBadRequest)
+		return nil, false
+	}
+	key, err := models.NewIdempotencyKey(header, operation, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	return key, true
+}
+
+// requestActor reads the optional X-Actor header naming who made a stock change, for
+// the ledger, answering 400 when it is too long.
+func requestActor(w http.ResponseWriter, r *http.Request) (string, bool) {
+	actor := strings.TrimSpace(r.Header.Get("X-Actor"))
+	if len(actor) > models.MaxActorLength {
+		http.Error(w, fmt.Sprintf("X-Actor must be at most %d characters", models.MaxActorLength), http.StatusBadRequest)
+		return "", false
+	}
+	return actor, true
+}
+
+// writeReplay sends the response recorded for an earlier request with the same key.
+func writeReplay(w http.ResponseWriter, replay *models.StoredResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(replay.StatusCode)
+	w.Write(replay.Body)
+	w.Write([]byte("\n"))
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/metalbear-co/metalmart/services/inventory/models"
+)
+
+// ListLocations returns the warehouses stock is held at.
+func (h *Handler) ListLocations(w http.ResponseWriter, r *http.Request) {
+	h.setDatabaseSourceHeader(w)
+
+	locations, err := h.store.ListLocations()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(locations)
+}
+
+// SetLocation creates a location or replaces its details. Deactivating a location
+// stops new reservations from being allocated there; its stock is kept.
+func (h *Handler) SetLocation(w http.ResponseWriter, r *http.Request) {
+	h.setDatabaseSourceHeader(w)
+	id := mux.Vars(r)["locationId"]
+
+	var in models.LocationInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	in.Normalize()
+	if err := in.Validate(id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	location, err := h.store.SetLocation(id, in)
+	if err != nil {
+		log.Printf("[%s] SetLocation FAILED location_id=%s: %v", h.dbSource, id, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[%s] SetLocation OK location_id=%s country=%s priority=%d active=%t", h.dbSource, id, location.Country, location.Priority, location.Active)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(location)
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/metalbear-co/metalmart/services/inventory/models"
+	"github.com/metalbear-co/metalmart/services/inventory/store"
+)
+
+const (
+	defaultMovementLimit = 50
+	maxMovementLimit     = 200
+)
+
+// ListMovements returns a product's stock ledger, newest first. variant_id,
+// location_id and type narrow it down; cursor continues from an earlier page's
+// next_cursor.
+func (h *Handler) ListMovements(w http.ResponseWriter, r *http.Request) {
+	h.setDatabaseSourceHeader(w)
+	productID := mux.Vars(r)["productId"]
+	q := r.URL.Query()
+
+	f := models.MovementFilter{
+		LocationID: q.Get("location_id"),
+		Type:       q.Get("type"),
+		Limit:      defaultMovementLimit,
+	}
+	if _, ok := q["variant_id"]; ok {
+		variantID := q.Get("variant_id")
+		f.VariantID = &variantID
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxMovementLimit {
+			http.Error(w, "limit must be between 1 and 200", http.StatusBadRequest)
+			return
+		}
+		f.Limit = n
+	}
+	if v := q.Get("cursor"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		f.Before = n
+	}
+
+	page, err := h.store.ListMovements(productID, f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// RecordMovement applies a receipt, adjustment or correction to a product's stock at
+// a location.
+func (h *Handler) RecordMovement(w http.ResponseWriter, r *http.Request) {
+	h.setDatabaseSourceHeader(w)
+	productID := mux.Vars(r)["productId"]
+
+	var in models.MovementInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	in.Normalize()
+	if err := in.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	actor, ok := requestActor(w, r)
+	if !ok {
+		return
+	}
+
+	m, err := h.store.RecordMovement(productID, in, actor)
+	switch {
+	case errors.Is(err, store.ErrStockNotFound), errors.Is(err, store.ErrLocationNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, store.ErrStockBelowReserved):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("[%s] RecordMovement FAILED product_id=%s variant_id=%s location_id=%s type=%s qty=%d: %v", h.dbSource, productID, in.VariantID, in.LocationID, in.Type, in.Quantity, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[%s] RecordMovement OK product_id=%s variant_id=%s location_id=%s type=%s qty=%d actor=%q", h.dbSource, productID, in.VariantID, in.LocationID, in.Type, in.Quantity, actor)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(m)
+}
+
+// GetReconciliation reports the stock levels of a product that differ from the sums
+// of its ledger, without changing them.
+func (h *Handler) GetReconciliation(w http.ResponseWriter, r *http.Request) {
+	h.reconcile(w, r, false)
+}
+
+// Reconcile rebuilds a product's stock levels and totals from its ledger and reports
+// the levels it corrected.
+func (h *Handler) Reconcile(w http.ResponseWriter, r *http.Request) {
+	h.reconcile(w, r, true)
+}
+
+func (h *Handler) reconcile(w http.ResponseWriter, r *http.Request, rebuild bool) {
+	h.setDatabaseSourceHeader(w)
+	productID := mux.Vars(r)["productId"]
+
+	result, err := h.store.Reconcile(productID, rebuild)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Inventory not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if rebuild && len(result.Discrepancies) > 0 {
+		log.Printf("[%s] Reconcile rebuilt product_id=%s from ledger: %d stock levels corrected", h.dbSource, productID, len(result.Discrepancies))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/metalbear-co/metalmart/services/inventory/models"
+)
+
+// SetReorderThreshold sets the available quantity at or below which a product is low
+// on stock. A null reorder_threshold reverts to the default.
+func (h *Handler) SetReorderThreshold(w http.ResponseWriter, r *http.Request) {
+	h.setDatabaseSourceHeader(w)
+
+	var in models.ReorderThresholdInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if in.ReorderThreshold != nil && *in.ReorderThreshold < 0 {
+		http.Error(w, "reorder_threshold must not be negative", http.StatusBadRequest)
+		return
+	}
+	h.setReorderThreshold(w, mux.Vars(r)["productId"], in.ReorderThreshold)
+}
+
+// ResetReorderThreshold reverts a product to the default reorder threshold.
+func (h *Handler) ResetReorderThreshold(w http.ResponseWriter, r *http.Request) {
+	h.setDatabaseSourceHeader(w)
+	h.setReorderThreshold(w, mux.Vars(r)["productId"], nil)
+}
+
+func (h *Handler) setReorderThreshold(w http.ResponseWriter, productID string, threshold *int) {
+	err := h.store.SetReorderThreshold(productID, threshold)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Inventory not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[%s] SetReorderThreshold FAILED product_id=%s: %v", h.dbSource, productID, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	inv, err := h.store.GetInventory(productID, "")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("[%s] SetReorderThreshold OK product_id=%s reorder_threshold=%d", h.dbSource, productID, inv.ReorderThreshold)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(inv)
+}
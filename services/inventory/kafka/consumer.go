@@ -0,0 +1,106 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/metalbear-co/metalmart/services/inventory/models"
+	"github.com/metalbear-co/metalmart/services/inventory/store"
+)
+
+var productTopics = []string{models.EventProductCreated, models.EventProductUpdated, models.EventProductDeleted}
+
+// applyRetryDelay is the wait before a product event that failed to apply is retried.
+const applyRetryDelay = 5 * time.Second
+
+// ProductConsumer keeps stock records in step with the catalogue by consuming its
+// product events.
+type ProductConsumer struct {
+	group sarama.ConsumerGroup
+	store *store.PostgresStore
+}
+
+func NewProductConsumer(brokers, groupID string, s *store.PostgresStore) (*ProductConsumer, error) {
+	config := sarama.NewConfig()
+	config.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategyRoundRobin()}
+	// A new group replays retained events so products created before inventory first
+	// started still get stock records.
+	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+
+	group, err := sarama.NewConsumerGroup(strings.Split(brokers, ","), groupID, config)
+	if err != nil {
+		return nil, err
+	}
+	return &ProductConsumer{group: group, store: s}, nil
+}
+
+// Run consumes until ctx is cancelled, retrying after errors.
+func (c *ProductConsumer) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+			if err := c.group.Consume(ctx, productTopics, c); err != nil {
+				log.Printf("Error consuming product events: %v", err)
+				time.Sleep(5 * time.Second)
+			}
+		}
+	}
+}
+
+func (c *ProductConsumer) Close() error {
+	return c.group.Close()
+}
+
+func (c *ProductConsumer) Setup(_ sarama.ConsumerGroupSession) error   { return nil }
+func (c *ProductConsumer) Cleanup(_ sarama.ConsumerGroupSession) error { return nil }
+
+func (c *ProductConsumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for msg := range claim.Messages() {
+		var event models.ProductEvent
+		if err := json.Unmarshal(msg.Value, &event); err != nil {
+			log.Printf("Failed to unmarshal product event: topic=%s offset=%d: %v", msg.Topic, msg.Offset, err)
+			session.MarkMessage(msg, "")
+			continue
+		}
+
+		// Store errors are retried in place, holding back the rest of the partition, so
+		// the event is only marked once inventory has applied it.
+		for {
+			err := c.apply(event)
+			if err == nil {
+				break
+			}
+			log.Printf("Failed to apply %s for product %s, retrying: %v", event.Type, event.ProductID, err)
+			select {
+			case <-session.Context().Done():
+				return nil
+			case <-time.After(applyRetryDelay):
+			}
+		}
+		session.MarkMessage(msg, "")
+	}
+	return nil
+}
+
+func (c *ProductConsumer) apply(event models.ProductEvent) error {
+	switch {
+	case event.Type == models.EventProductDeleted:
+		log.Printf("Retiring inventory of deleted product %s", event.ProductID)
+		return c.store.RetireProduct(event.ProductID, event.OccurredAt)
+	case event.Product == nil:
+		log.Printf("Ignoring %s for product %s without product body", event.Type, event.ProductID)
+		return nil
+	case event.Product.Archived():
+		log.Printf("Retiring inventory of archived product %s", event.ProductID)
+		return c.store.RetireProduct(event.ProductID, event.OccurredAt)
+	default:
+		log.Printf("Syncing inventory of product %s from %s", event.ProductID, event.Type)
+		return c.store.SyncProduct(event.ProductID, event.Product.VariantIDs(), event.OccurredAt)
+	}
+}
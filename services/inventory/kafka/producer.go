@@ -0,0 +1,67 @@
+package kafka
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/IBM/sarama"
+	"github.com/metalbear-co/metalmart/services/inventory/models"
+)
+
+type Producer struct {
+	producer sarama.SyncProducer
+}
+
+func NewProducer(brokers string) (*Producer, error) {
+	config := sarama.NewConfig()
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Retry.Max = 5
+	config.Producer.Return.Successes = true
+
+	brokerList := strings.Split(brokers, ",")
+	producer, err := sarama.NewSyncProducer(brokerList, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Producer{producer: producer}, nil
+}
+
+func (p *Producer) Close() error {
+	return p.producer.Close()
+}
+
+// PublishReservationExpired sends event keyed by reservation id.
+func (p *Producer) PublishReservationExpired(event models.ReservationExpiredEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	msg := &sarama.ProducerMessage{
+		Topic: event.Type,
+		Key:   sarama.StringEncoder(event.ReservationID),
+		Value: sarama.ByteEncoder(data),
+	}
+
+	_, _, err = p.producer.SendMessage(msg)
+	return err
+}
+
+// PublishStockAlert sends event keyed by product id, so a product's alerts stay in
+// order.
+func (p *Producer) PublishStockAlert(event models.StockAlertEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	msg := &sarama.ProducerMessage{
+		Topic: event.Type,
+		Key:   sarama.StringEncoder(event.ProductID),
+		Value: sarama.ByteEncoder(data),
+	}
+
+	_, _, err = p.producer.SendMessage(msg)
+	return err
+}
@@ -1,13 +1,18 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/metalbear-co/metalmart/services/inventory/handlers"
+	"github.com/metalbear-co/metalmart/services/inventory/kafka"
+	"github.com/metalbear-co/metalmart/services/inventory/notifier"
+	"github.com/metalbear-co/metalmart/services/inventory/reaper"
 	"github.com/metalbear-co/metalmart/services/inventory/store"
 )
 
@@ -52,11 +57,61 @@ func main() {
 		log.Printf("Warning: Failed to seed inventory from catalogue: %v", err)
 	}
 
+	kafkaBrokers := os.Getenv("KAFKA_BROKERS")
+	if kafkaBrokers == "" {
+		kafkaBrokers = "localhost:9092"
+	}
+	kafkaGroupID := os.Getenv("KAFKA_GROUP_ID")
+	if kafkaGroupID == "" {
+		kafkaGroupID = "inventory"
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	consumer, err := kafka.NewProductConsumer(kafkaBrokers, kafkaGroupID, db)
+	if err != nil {
+		log.Printf("Warning: Failed to connect to Kafka, stock records will not follow catalogue changes: %v", err)
+	} else {
+		defer consumer.Close()
+		go consumer.Run(ctx)
+	}
+
+	reservationTTL := 15 * time.Minute
+	if v := os.Getenv("RESERVATION_TTL"); v != "" {
+		if reservationTTL, err = time.ParseDuration(v); err != nil || reservationTTL <= 0 {
+			log.Fatalf("Invalid RESERVATION_TTL %q: must be a positive duration", v)
+		}
+	}
+	reaperInterval := time.Minute
+	if v := os.Getenv("RESERVATION_REAPER_INTERVAL"); v != "" {
+		if reaperInterval, err = time.ParseDuration(v); err != nil || reaperInterval <= 0 {
+			log.Fatalf("Invalid RESERVATION_REAPER_INTERVAL %q: must be a positive duration", v)
+		}
+	}
+	stockAlertInterval := 5 * time.Second
+	if v := os.Getenv("STOCK_ALERT_INTERVAL"); v != "" {
+		if stockAlertInterval, err = time.ParseDuration(v); err != nil || stockAlertInterval <= 0 {
+			log.Fatalf("Invalid STOCK_ALERT_INTERVAL %q: must be a positive duration", v)
+		}
+	}
+
+	producer, err := kafka.NewProducer(kafkaBrokers)
+	if err != nil {
+		log.Printf("Warning: Failed to connect to Kafka, expired reservations and stock alerts will only be logged: %v", err)
+		producer = nil
+	}
+	if producer != nil {
+		defer producer.Close()
+	}
+	go reaper.New(db, producer, reservationTTL, reaperInterval).Run(ctx)
+	go notifier.New(db, producer, stockAlertInterval).Run(ctx)
+
 	dbSource := "cluster"
 	if os.Getenv("MIRRORD_DB_BRANCH") == "true" {
 		dbSource = "mirrord-db-branch"
 	}
-	h := handlers.NewHandler(db, dbSource)
+	h := handlers.NewHandler(db, dbSource, reservationTTL)
 
 	r := mux.NewRouter()
 
@@ -71,6 +126,14 @@ func main() {
 	api.HandleFunc("/inventory/release", h.Release).Methods("POST")
 	api.HandleFunc("/inventory/confirm", h.Confirm).Methods("POST")
 	api.HandleFunc("/inventory/init", h.InitInventory).Methods("POST")
+	api.HandleFunc("/inventory/{productId}/movements", h.ListMovements).Methods("GET")
+	api.HandleFunc("/inventory/{productId}/movements", h.RecordMovement).Methods("POST")
+	api.HandleFunc("/inventory/{productId}/reconciliation", h.GetReconciliation).Methods("GET")
+	api.HandleFunc("/inventory/{productId}/reconciliation", h.Reconcile).Methods("POST")
+	api.HandleFunc("/inventory/{productId}/reorder-threshold", h.SetReorderThreshold).Methods("PUT")
+	api.HandleFunc("/inventory/{productId}/reorder-threshold", h.ResetReorderThreshold).Methods("DELETE")
+	api.HandleFunc("/locations", h.ListLocations).Methods("GET")
+	api.HandleFunc("/locations/{locationId}", h.SetLocation).Methods("PUT")
 
 	handler := corsMiddleware(r)
 
@@ -100,7 +163,8 @@ func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key, X-Actor")
+		w.Header().Set("Access-Control-Expose-Headers", "Idempotent-Replayed")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -0,0 +1,89 @@
+package models
+
+import "time"
+
+// Product change events published by the catalogue service on topics of the same name.
+const (
+	EventProductCreated = "product.created"
+	EventProductUpdated = "product.updated"
+	EventProductDeleted = "product.deleted"
+)
+
+// ProductEvent is the part of a catalogue product event inventory cares about.
+// Product is absent for product.deleted.
+type ProductEvent struct {
+	Type       string          `json:"type"`
+	ProductID  string          `json:"product_id"`
+	Product    *CatalogueEntry `json:"product,omitempty"`
+	OccurredAt time.Time       `json:"occurred_at"`
+}
+
+type CatalogueEntry struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"`
+	Variants []struct {
+		ID string `json:"id"`
+	} `json:"variants"`
+}
+
+// Archived reports whether the product can no longer be sold.
+func (p CatalogueEntry) Archived() bool {
+	return p.Status == "archived"
+}
+
+// VariantIDs returns the variant ids stock is tracked under: one per variant, or ""
+// for a product without variants.
+func (p CatalogueEntry) VariantIDs() []string {
+	if len(p.Variants) == 0 {
+		return []string{""}
+	}
+	ids := make([]string, len(p.Variants))
+	for i, v := range p.Variants {
+		ids[i] = v.ID
+	}
+	return ids
+}
+
+// EventReservationExpired is published by inventory on the topic of the same name when
+// the reaper releases a reservation that was never confirmed or released.
+const EventReservationExpired = "inventory.reservation_expired"
+
+type ReservationExpiredEvent struct {
+	Type string `json:"type"`
+	ExpiredReservation
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// ExpiredReservation is a reservation the reaper released and the stock it gave back.
+type ExpiredReservation struct {
+	ReservationID string        `json:"reservation_id"`
+	Items         []ReserveItem `json:"items"`
+	ExpiresAt     time.Time     `json:"expires_at"`
+}
+
+// Stock alert events, published on topics of the same name when the available stock
+// of a product or variant crosses its reorder threshold: low_stock when it falls to
+// the threshold or below, out_of_stock when none is left, back_in_stock when an
+// out-of-stock record can be sold again, and stock_recovered when a low-stock record
+// rises above its threshold.
+const (
+	EventLowStock       = "inventory.low_stock"
+	EventOutOfStock     = "inventory.out_of_stock"
+	EventBackInStock    = "inventory.back_in_stock"
+	EventStockRecovered = "inventory.stock_recovered"
+)
+
+// StockAlertEvent reports a stock state change. StockState is the state the record
+// moved to, so a back_in_stock record that is still low on stock can be told apart. ID
+// is unique per change, so consumers can discard redeliveries; OccurredAt is when the
+// change was committed.
+type StockAlertEvent struct {
+	ID               int64     `json:"id"`
+	Type             string    `json:"type"`
+	ProductID        string    `json:"product_id"`
+	VariantID        string    `json:"variant_id,omitempty"`
+	StockState       string    `json:"stock_state"`
+	Available        int       `json:"available"`
+	ReorderThreshold int       `json:"reorder_threshold"`
+	OccurredAt       time.Time `json:"occurred_at"`
+}
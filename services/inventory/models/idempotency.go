@@ -0,0 +1,47 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// Operations an idempotency key is scoped to; the same key may be used once per operation.
+const (
+	OperationReserve = "reserve"
+	OperationConfirm = "confirm"
+	OperationRelease = "release"
+)
+
+// MaxIdempotencyKeyLength bounds the Idempotency-Key header.
+const MaxIdempotencyKeyLength = 255
+
+// IdempotencyKey identifies a client request that may be retried. RequestHash
+// fingerprints the payload so a key reused for a different request can be refused.
+type IdempotencyKey struct {
+	Key         string
+	Operation   string
+	RequestHash string
+}
+
+// NewIdempotencyKey returns the key for a request to operation, or nil when the client
+// sent no key. The hash is taken over the decoded request, so formatting differences
+// between retries do not matter.
+func NewIdempotencyKey(key, operation string, request interface{}) (*IdempotencyKey, error) {
+	if key == "" {
+		return nil, nil
+	}
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	return &IdempotencyKey{Key: key, Operation: operation, RequestHash: hex.EncodeToString(sum[:])}, nil
+}
+
+// StoredResponse is the response recorded for an idempotency key, replayed verbatim
+// when the request is retried.
+type StoredResponse struct {
+	StatusCode int
+	Body       json.RawMessage
+}
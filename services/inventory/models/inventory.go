@@ -1,39 +1,113 @@
 package models
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
 
+// Inventory is the stock of a product, or of one of its variants when VariantID is set.
+// For a product with variants, GetInventory without a variant returns the totals across
+// all of them with the per-variant rows in Variants. Quantities are totals over all
+// locations; Locations breaks them down. StockState compares the available quantity
+// with the product's ReorderThreshold.
 type Inventory struct {
-	ID               string    `json:"id"`
-	ProductID        string    `json:"product_id"`
-	StockQuantity    int       `json:"stock_quantity"`
-	ReservedQuantity int       `json:"reserved_quantity"`
-	LastUpdated      time.Time `json:"last_updated"`
+	ID               string          `json:"id,omitempty"`
+	ProductID        string          `json:"product_id"`
+	VariantID        string          `json:"variant_id,omitempty"`
+	StockQuantity    int             `json:"stock_quantity"`
+	ReservedQuantity int             `json:"reserved_quantity"`
+	ReorderThreshold int             `json:"reorder_threshold"`
+	StockState       string          `json:"stock_state"`
+	Locations        []LocationStock `json:"locations"`
+	LastUpdated      time.Time       `json:"last_updated"`
+	Variants         []Inventory     `json:"variants,omitempty"`
 }
 
+// ReserveRequest asks to hold stock for items. ShippingCountry (ISO 3166 alpha-2)
+// ranks locations in that country first; Strategy defaults to AllocateSingleLocation.
 type ReserveRequest struct {
-	Items []ReserveItem `json:"items"`
+	Items           []ReserveItem `json:"items"`
+	ShippingCountry string        `json:"shipping_country,omitempty"`
+	Strategy        string        `json:"strategy,omitempty"`
+}
+
+func (r *ReserveRequest) Normalize() {
+	r.ShippingCountry = strings.ToUpper(strings.TrimSpace(r.ShippingCountry))
+	r.Strategy = strings.ToLower(strings.TrimSpace(r.Strategy))
+	if r.Strategy == "" {
+		r.Strategy = AllocateSingleLocation
+	}
+}
+
+func (r ReserveRequest) Validate() error {
+	if len(r.Items) == 0 {
+		return errors.New("items must not be empty")
+	}
+	for _, item := range r.Items {
+		if item.Quantity <= 0 {
+			return fmt.Errorf("quantity for %s must be positive", item)
+		}
+	}
+	if r.ShippingCountry != "" && !validCountry(r.ShippingCountry) {
+		return errors.New("shipping_country must be a two-letter ISO 3166 code")
+	}
+	switch r.Strategy {
+	case AllocateSingleLocation, AllocateNearest:
+	default:
+		return fmt.Errorf("strategy must be %s or %s", AllocateSingleLocation, AllocateNearest)
+	}
+	return nil
 }
 
 type ReserveItem struct {
 	ProductID string `json:"product_id"`
+	VariantID string `json:"variant_id,omitempty"`
 	Quantity  int    `json:"quantity"`
 }
 
+func (i ReserveItem) String() string {
+	if i.VariantID != "" {
+		return fmt.Sprintf("product %s variant %s", i.ProductID, i.VariantID)
+	}
+	return fmt.Sprintf("product %s", i.ProductID)
+}
+
+// ReserveResponse reports a new reservation. Unless it is confirmed or released
+// before ExpiresAt, the reaper releases it.
 type ReserveResponse struct {
-	ReservationID string `json:"reservation_id"`
-	Success       bool   `json:"success"`
-	Message       string `json:"message,omitempty"`
+	ReservationID string       `json:"reservation_id"`
+	ExpiresAt     *time.Time   `json:"expires_at,omitempty"`
+	Allocations   []Allocation `json:"allocations,omitempty"`
+	Success       bool         `json:"success"`
+	Message       string       `json:"message,omitempty"`
+}
+
+// OperationResult is the response body of release and confirm.
+type OperationResult struct {
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
 }
 
 type ReleaseRequest struct {
 	ReservationID string `json:"reservation_id"`
 }
 
+// ConfirmRequest confirms a reservation. OrderID, when set, is recorded as the
+// reference of the ledger entries taking the stock out.
 type ConfirmRequest struct {
 	ReservationID string `json:"reservation_id"`
+	OrderID       string `json:"order_id,omitempty"`
 }
 
+// InitInventoryRequest sets the stock of a product or variant at a location,
+// DefaultLocationID when LocationID is empty. The change is recorded in the ledger as
+// a receipt for a new stock level and as an adjustment otherwise.
 type InitInventoryRequest struct {
-	ProductID string `json:"product_id"`
-	Quantity  int    `json:"quantity"`
+	ProductID  string `json:"product_id"`
+	VariantID  string `json:"variant_id,omitempty"`
+	LocationID string `json:"location_id,omitempty"`
+	Quantity   int    `json:"quantity"`
+	Reason     string `json:"reason,omitempty"`
 }
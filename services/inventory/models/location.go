@@ -0,0 +1,91 @@
+package models
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultLocationID is the warehouse stock was held in before locations existed. Stock
+// set without a location goes there.
+const DefaultLocationID = "main"
+
+// Allocation strategies for reservations. Both try locations in the shipping country
+// first, then by ascending priority. SingleLocation keeps an order in one location
+// when any location can ship all of it, splitting only otherwise; Nearest fills each
+// item from the best ranked locations independently.
+const (
+	AllocateSingleLocation = "single_location"
+	AllocateNearest        = "nearest"
+)
+
+var locationIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,49}$`)
+
+// Location is a warehouse stock is held and shipped from. Inactive locations keep
+// their stock records but are not allocated from.
+type Location struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Country   string    `json:"country"`
+	Priority  int       `json:"priority"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LocationInput is the body accepted by PUT /api/locations/{locationId}.
+type LocationInput struct {
+	Name     string `json:"name"`
+	Country  string `json:"country"`
+	Priority int    `json:"priority"`
+	Active   *bool  `json:"active"`
+}
+
+func (in *LocationInput) Normalize() {
+	in.Name = strings.TrimSpace(in.Name)
+	in.Country = strings.ToUpper(strings.TrimSpace(in.Country))
+	if in.Active == nil {
+		active := true
+		in.Active = &active
+	}
+}
+
+func (in LocationInput) Validate(id string) error {
+	if !locationIDPattern.MatchString(id) {
+		return errors.New("location id must be 1 to 50 lowercase letters, digits, '-' or '_'")
+	}
+	if in.Name == "" || len(in.Name) > 255 {
+		return errors.New("name must be 1 to 255 characters")
+	}
+	if !validCountry(in.Country) {
+		return errors.New("country must be a two-letter ISO 3166 code")
+	}
+	return nil
+}
+
+// LocationStock is the stock of a product or variant held at one location.
+type LocationStock struct {
+	LocationID       string `json:"location_id"`
+	StockQuantity    int    `json:"stock_quantity"`
+	ReservedQuantity int    `json:"reserved_quantity"`
+}
+
+// Allocation is the part of a reservation taken from one location.
+type Allocation struct {
+	ProductID  string `json:"product_id"`
+	VariantID  string `json:"variant_id,omitempty"`
+	LocationID string `json:"location_id"`
+	Quantity   int    `json:"quantity"`
+}
+
+func validCountry(country string) bool {
+	if len(country) != 2 {
+		return false
+	}
+	for _, c := range country {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Movement types. Receipts, adjustments and corrections change the stock on hand at a
+// location; reservations and releases move stock between available and reserved, and
+// a confirmation takes reserved stock out of stock.
+const (
+	MovementReceipt      = "receipt"
+	MovementAdjustment   = "adjustment"
+	MovementCorrection   = "correction"
+	MovementReservation  = "reservation"
+	MovementRelease      = "release"
+	MovementConfirmation = "confirmation"
+)
+
+// MaxActorLength bounds the X-Actor header recorded on movements.
+const MaxActorLength = 100
+
+// Movement is an entry in the append-only stock ledger. Summing StockDelta and
+// ReservedDelta over a product, variant and location gives its stock level.
+type Movement struct {
+	ID            int64     `json:"id"`
+	ProductID     string    `json:"product_id"`
+	VariantID     string    `json:"variant_id,omitempty"`
+	LocationID    string    `json:"location_id"`
+	Type          string    `json:"type"`
+	StockDelta    int       `json:"stock_delta"`
+	ReservedDelta int       `json:"reserved_delta"`
+	Reason        string    `json:"reason,omitempty"`
+	Actor         string    `json:"actor,omitempty"`
+	ReservationID string    `json:"reservation_id,omitempty"`
+	Reference     string    `json:"reference,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// MovementFilter selects movements of a product, newest first. Empty fields match
+// everything; Before continues from the NextCursor of an earlier page.
+type MovementFilter struct {
+	VariantID  *string
+	LocationID string
+	Type       string
+	Before     int64
+	Limit      int
+}
+
+type MovementPage struct {
+	Movements  []Movement `json:"movements"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+// MovementInput is the body of POST /api/inventory/{productId}/movements: a change
+// to the stock on hand at a location. Quantity is added to the stock, so an
+// adjustment or correction that removes stock is negative.
+type MovementInput struct {
+	VariantID  string `json:"variant_id,omitempty"`
+	LocationID string `json:"location_id,omitempty"`
+	Type       string `json:"type"`
+	Quantity   int    `json:"quantity"`
+	Reason     string `json:"reason,omitempty"`
+	Reference  string `json:"reference,omitempty"`
+}
+
+func (in *MovementInput) Normalize() {
+	in.VariantID = strings.TrimSpace(in.VariantID)
+	in.LocationID = strings.TrimSpace(in.LocationID)
+	if in.LocationID == "" {
+		in.LocationID = DefaultLocationID
+	}
+	in.Type = strings.ToLower(strings.TrimSpace(in.Type))
+	in.Reason = strings.TrimSpace(in.Reason)
+	in.Reference = strings.TrimSpace(in.Reference)
+}
+
+func (in MovementInput) Validate() error {
+	switch in.Type {
+	case MovementReceipt:
+		if in.Quantity <= 0 {
+			return errors.New("quantity of a receipt must be positive")
+		}
+	case MovementAdjustment, MovementCorrection:
+		if in.Quantity == 0 {
+			return errors.New("quantity must not be zero")
+		}
+		if in.Reason == "" {
+			return fmt.Errorf("reason is required for an %s", in.Type)
+		}
+	default:
+		return fmt.Errorf("type must be %s, %s or %s", MovementReceipt, MovementAdjustment, MovementCorrection)
+	}
+	if len(in.Reason) > 500 {
+		return errors.New("reason must be at most 500 characters")
+	}
+	if len(in.Reference) > 255 {
+		return errors.New("reference must be at most 255 characters")
+	}
+	return nil
+}
+
+// StockDiscrepancy is a stock level whose recorded quantities differ from the sum of
+// its movements in the ledger.
+type StockDiscrepancy struct {
+	ProductID        string `json:"product_id"`
+	VariantID        string `json:"variant_id,omitempty"`
+	LocationID       string `json:"location_id"`
+	StockQuantity    int    `json:"stock_quantity"`
+	ReservedQuantity int    `json:"reserved_quantity"`
+	LedgerStock      int    `json:"ledger_stock_quantity"`
+	LedgerReserved   int    `json:"ledger_reserved_quantity"`
+}
+
+// Reconciliation compares a product's stock levels with its ledger. Rebuilt reports
+// whether the levels were reset to the ledger's quantities.
+type Reconciliation struct {
+	ProductID     string             `json:"product_id"`
+	Discrepancies []StockDiscrepancy `json:"discrepancies"`
+	Rebuilt       bool               `json:"rebuilt"`
+}
@@ -0,0 +1,50 @@
+package models
+
+// DefaultReorderThreshold is the available quantity at or below which a product
+// without its own threshold is low on stock.
+const DefaultReorderThreshold = 5
+
+// Stock states of a product or variant, from its available (stock minus reserved)
+// quantity and reorder threshold.
+const (
+	StockIn  = "in_stock"
+	StockLow = "low_stock"
+	StockOut = "out_of_stock"
+)
+
+// StockState returns the state of available stock against threshold. A threshold of
+// zero turns low-stock alerts off.
+func StockState(available, threshold int) string {
+	switch {
+	case available <= 0:
+		return StockOut
+	case available <= threshold:
+		return StockLow
+	default:
+		return StockIn
+	}
+}
+
+// StockAlert returns the event type for a change from state previous to state current,
+// or "" when the change is not worth an alert.
+func StockAlert(previous, current string) string {
+	switch {
+	case previous == current:
+		return ""
+	case current == StockOut:
+		return EventOutOfStock
+	case previous == StockOut:
+		return EventBackInStock
+	case current == StockLow:
+		return EventLowStock
+	case previous == StockLow:
+		return EventStockRecovered
+	default:
+		return ""
+	}
+}
+
+// ReorderThresholdInput is the body of PUT /api/inventory/{productId}/reorder-threshold.
+type ReorderThresholdInput struct {
+	ReorderThreshold *int `json:"reorder_threshold"`
+}
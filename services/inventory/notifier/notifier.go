@@ -0,0 +1,87 @@
+// Package notifier publishes the low-stock, out-of-stock and back-in-stock alerts the
+// store queues as stock changes.
+package notifier
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/metalbear-co/metalmart/services/inventory/kafka"
+	"github.com/metalbear-co/metalmart/services/inventory/models"
+	"github.com/metalbear-co/metalmart/services/inventory/store"
+)
+
+const (
+	// batchSize bounds the alerts published per transaction.
+	batchSize = 100
+	// alertRetention is how long published alerts are kept for inspection.
+	alertRetention = 7 * 24 * time.Hour
+)
+
+// Notifier periodically publishes queued stock alerts. Several inventory replicas can
+// run one each; the store skips alerts another replica is publishing.
+type Notifier struct {
+	store    *store.PostgresStore
+	producer *kafka.Producer
+	interval time.Duration
+}
+
+// New returns a Notifier that checks every interval. producer may be nil, in which
+// case alerts are only logged.
+func New(s *store.PostgresStore, producer *kafka.Producer, interval time.Duration) *Notifier {
+	return &Notifier{store: s, producer: producer, interval: interval}
+}
+
+// Run publishes until ctx is cancelled.
+func (n *Notifier) Run(ctx context.Context) {
+	ticker := time.NewTicker(n.interval)
+	defer ticker.Stop()
+	var lastPurge time.Time
+	for {
+		n.publishAll()
+		if time.Since(lastPurge) >= time.Hour {
+			n.purge()
+			lastPurge = time.Now()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publishAll publishes queued alerts batch by batch until none are left or Kafka
+// fails, in which case the rest wait for the next tick.
+func (n *Notifier) publishAll() {
+	for {
+		published, err := n.store.PublishStockAlerts(batchSize, n.publish)
+		if err != nil {
+			log.Printf("Error publishing stock alerts: %v", err)
+			return
+		}
+		if published < batchSize {
+			return
+		}
+	}
+}
+
+func (n *Notifier) publish(event models.StockAlertEvent) error {
+	log.Printf("Stock alert %s product_id=%s variant_id=%s available=%d reorder_threshold=%d", event.Type, event.ProductID, event.VariantID, event.Available, event.ReorderThreshold)
+	if n.producer == nil {
+		return nil
+	}
+	return n.producer.PublishStockAlert(event)
+}
+
+func (n *Notifier) purge() {
+	count, err := n.store.PurgeStockAlerts(alertRetention)
+	if err != nil {
+		log.Printf("Error purging stock alerts: %v", err)
+		return
+	}
+	if count > 0 {
+		log.Printf("Purged %d stock alerts older than %s", count, alertRetention)
+	}
+}
@@ -0,0 +1,95 @@
+// Package reaper releases inventory reservations that checkout abandoned.
+package reaper
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/metalbear-co/metalmart/services/inventory/kafka"
+	"github.com/metalbear-co/metalmart/services/inventory/models"
+	"github.com/metalbear-co/metalmart/services/inventory/store"
+)
+
+const (
+	// batchSize bounds the reservations released per transaction.
+	batchSize = 100
+	// idempotencyRetention is how long reserve, confirm and release responses are kept
+	// for replay; it comfortably outlives any client retry.
+	idempotencyRetention = 24 * time.Hour
+)
+
+// Reaper periodically releases reservations past their expiry and purges old
+// idempotency keys. Several inventory replicas can run one each; the store skips rows
+// another replica is releasing.
+type Reaper struct {
+	store    *store.PostgresStore
+	producer *kafka.Producer
+	ttl      time.Duration
+	interval time.Duration
+}
+
+// New returns a Reaper that checks every interval. ttl is the reservation TTL, used for
+// reservations made before expiry was recorded. producer may be nil, in which case
+// expiries are only logged.
+func New(s *store.PostgresStore, producer *kafka.Producer, ttl, interval time.Duration) *Reaper {
+	return &Reaper{store: s, producer: producer, ttl: ttl, interval: interval}
+}
+
+// Run reaps until ctx is cancelled.
+func (r *Reaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		r.reap()
+		r.purgeIdempotencyKeys()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reap releases expired reservations batch by batch until none are left.
+func (r *Reaper) reap() {
+	for {
+		expired, err := r.store.ExpireReservations(r.ttl, batchSize)
+		if err != nil {
+			log.Printf("Error expiring reservations: %v", err)
+			return
+		}
+		for _, res := range expired {
+			log.Printf("Reservation expired reservation_id=%s items=%v expires_at=%s", res.ReservationID, res.Items, res.ExpiresAt.Format(time.RFC3339))
+			r.publish(res)
+		}
+		if len(expired) == 0 {
+			return
+		}
+	}
+}
+
+func (r *Reaper) purgeIdempotencyKeys() {
+	n, err := r.store.PurgeIdempotencyKeys(idempotencyRetention)
+	if err != nil {
+		log.Printf("Error purging idempotency keys: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("Purged %d idempotency keys older than %s", n, idempotencyRetention)
+	}
+}
+
+func (r *Reaper) publish(res models.ExpiredReservation) {
+	if r.producer == nil {
+		return
+	}
+	event := models.ReservationExpiredEvent{
+		Type:               models.EventReservationExpired,
+		ExpiredReservation: res,
+		OccurredAt:         time.Now().UTC(),
+	}
+	if err := r.producer.PublishReservationExpired(event); err != nil {
+		log.Printf("Warning: Failed to publish %s for reservation %s: %v", event.Type, res.ReservationID, err)
+	}
+}
@@ -0,0 +1,202 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/metalbear-co/metalmart/services/inventory/models"
+)
+
+// migrateStockAlerts adds per-product reorder thresholds and the last stock state of
+// each record. Changes of state are queued in stock_alerts in the transaction that
+// caused them and published to Kafka from there, so an alert is neither lost when
+// Kafka is down nor sent for a change that rolled back.
+func (s *PostgresStore) migrateStockAlerts() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS reorder_thresholds (
+		product_id VARCHAR(50) PRIMARY KEY,
+		threshold INTEGER NOT NULL CHECK (threshold >= 0),
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+	ALTER TABLE inventory ADD COLUMN IF NOT EXISTS stock_state VARCHAR(20);
+
+	CREATE TABLE IF NOT EXISTS stock_alerts (
+		id BIGSERIAL PRIMARY KEY,
+		type VARCHAR(50) NOT NULL,
+		product_id VARCHAR(50) NOT NULL,
+		variant_id VARCHAR(50) NOT NULL DEFAULT '',
+		available INTEGER NOT NULL,
+		reorder_threshold INTEGER NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		published_at TIMESTAMP
+	);
+	ALTER TABLE stock_alerts ADD COLUMN IF NOT EXISTS stock_state VARCHAR(20) NOT NULL DEFAULT '';
+	CREATE INDEX IF NOT EXISTS idx_stock_alerts_unpublished ON stock_alerts(id) WHERE published_at IS NULL;
+	`
+	if _, err := s.db.Exec(query); err != nil {
+		return err
+	}
+
+	// Records that predate stock states start from their current state without an alert.
+	_, err := s.db.Exec(`
+		UPDATE inventory i SET stock_state = CASE
+			WHEN i.stock_quantity - i.reserved_quantity <= 0 THEN 'out_of_stock'
+			WHEN i.stock_quantity - i.reserved_quantity <= COALESCE((SELECT threshold FROM reorder_thresholds t WHERE t.product_id = i.product_id), $1) THEN 'low_stock'
+			ELSE 'in_stock'
+		END
+		WHERE i.stock_state IS NULL AND i.retired_at IS NULL
+	`, models.DefaultReorderThreshold)
+	return err
+}
+
+// SetReorderThreshold sets a product's reorder threshold, or with nil, reverts it to
+// DefaultReorderThreshold. The product's records move to the state the new threshold
+// puts them in, alerting as they would after a stock change.
+func (s *PostgresStore) SetReorderThreshold(productID string, threshold *int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT variant_id FROM inventory WHERE product_id = $1 AND retired_at IS NULL
+		ORDER BY variant_id
+		FOR UPDATE
+	`, productID)
+	if err != nil {
+		return err
+	}
+	var keys []skuKey
+	for rows.Next() {
+		key := skuKey{ProductID: productID}
+		if err := rows.Scan(&key.VariantID); err != nil {
+			rows.Close()
+			return err
+		}
+		keys = append(keys, key)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return sql.ErrNoRows
+	}
+
+	if threshold == nil {
+		_, err = tx.Exec(`DELETE FROM reorder_thresholds WHERE product_id = $1`, productID)
+	} else {
+		_, err = tx.Exec(`
+			INSERT INTO reorder_thresholds (product_id, threshold) VALUES ($1, $2)
+			ON CONFLICT (product_id) DO UPDATE SET threshold = $2, updated_at = NOW()
+		`, productID, *threshold)
+	}
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := refreshTotals(tx, key); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// reorderThreshold returns the reorder threshold of a product.
+func (s *PostgresStore) reorderThreshold(productID string) (int, error) {
+	var threshold int
+	err := s.db.QueryRow(`
+		SELECT COALESCE((SELECT threshold FROM reorder_thresholds WHERE product_id = $1), $2)
+	`, productID, models.DefaultReorderThreshold).Scan(&threshold)
+	return threshold, err
+}
+
+// updateStockState moves an active stock record to the state of its available stock
+// and queues an alert when the change calls for one. previous is invalid for a record
+// that has no state yet, such as one just created or restored, which takes its first
+// state silently.
+func updateStockState(tx *sql.Tx, key skuKey, previous sql.NullString, available, threshold int) error {
+	state := models.StockState(available, threshold)
+	if previous.Valid && previous.String == state {
+		return nil
+	}
+	_, err := tx.Exec(`UPDATE inventory SET stock_state = $3 WHERE product_id = $1 AND variant_id = $2`, key.ProductID, key.VariantID, state)
+	if err != nil || !previous.Valid {
+		return err
+	}
+
+	alert := models.StockAlert(previous.String, state)
+	if alert == "" {
+		return nil
+	}
+	_, err = tx.Exec(`
+		INSERT INTO stock_alerts (type, product_id, variant_id, stock_state, available, reorder_threshold)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, alert, key.ProductID, key.VariantID, state, available, threshold)
+	return err
+}
+
+// PublishStockAlerts hands up to limit queued alerts to publish, oldest first, and
+// marks those it accepted as published. It stops at the first alert publish fails on,
+// leaving it and the rest queued. Alerts being published by another replica are
+// skipped.
+func (s *PostgresStore) PublishStockAlerts(limit int, publish func(models.StockAlertEvent) error) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id, type, product_id, variant_id, stock_state, available, reorder_threshold, created_at
+		FROM stock_alerts
+		WHERE published_at IS NULL
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`, limit)
+	if err != nil {
+		return 0, err
+	}
+	var alerts []models.StockAlertEvent
+	for rows.Next() {
+		var a models.StockAlertEvent
+		if err := rows.Scan(&a.ID, &a.Type, &a.ProductID, &a.VariantID, &a.StockState, &a.Available, &a.ReorderThreshold, &a.OccurredAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		alerts = append(alerts, a)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	var publishErr error
+	for _, a := range alerts {
+		if publishErr = publish(a); publishErr != nil {
+			break
+		}
+		if _, err := tx.Exec(`UPDATE stock_alerts SET published_at = NOW() WHERE id = $1`, a.ID); err != nil {
+			return 0, err
+		}
+		published++
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return published, publishErr
+}
+
+// PurgeStockAlerts deletes alerts published before now minus retention.
+func (s *PostgresStore) PurgeStockAlerts(retention time.Duration) (int64, error) {
+	res, err := s.db.Exec(`DELETE FROM stock_alerts WHERE published_at < NOW() - make_interval(secs => $1)`, retention.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
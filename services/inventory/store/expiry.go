@@ -0,0 +1,145 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/metalbear-co/metalmart/services/inventory/models"
+)
+
+// migrateReservationExpiry adds the time a pending reservation is released if checkout
+// never confirms or releases it. Reservations made before the column existed have no
+// expires_at and expire a TTL after created_at.
+func (s *PostgresStore) migrateReservationExpiry() error {
+	query := `
+	ALTER TABLE reservations ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP;
+	CREATE INDEX IF NOT EXISTS idx_reservations_pending_expiry ON reservations(expires_at) WHERE status = 'pending';
+	`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+// ExpireReservations releases up to limit pending reservations that are past their
+// expiry, marking every row of each one expired and returning their stock in one
+// transaction. A reservation any of whose rows are locked by a concurrent release,
+// confirm or another reaper is skipped whole, so a reservation is never split across
+// batches nor given back twice. legacyTTL dates rows without expires_at.
+func (s *PostgresStore) ExpireReservations(legacyTTL time.Duration, limit int) ([]models.ExpiredReservation, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT reservation_id FROM reservations
+		WHERE status = 'pending' AND COALESCE(expires_at, created_at + make_interval(secs => $1)) <= NOW()
+		GROUP BY reservation_id
+		ORDER BY MIN(created_at)
+		LIMIT $2
+	`, legacyTTL.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	var due []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		due = append(due, id)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var expired []models.ExpiredReservation
+	var released []reservationRow
+	for _, id := range due {
+		res, resRows, err := expireReservation(tx, id, legacyTTL)
+		if err != nil {
+			return nil, err
+		}
+		if res == nil {
+			continue
+		}
+		expired = append(expired, *res)
+		released = append(released, resRows...)
+	}
+
+	release := models.Movement{Type: models.MovementRelease, Reason: "reservation expired", Actor: "reaper"}
+	if err := settleReservations(tx, released, release); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return expired, nil
+}
+
+// expireReservation marks all pending rows of a reservation that is still past its
+// expiry as expired. It returns nil when the reservation was settled in the meantime
+// or some of its rows are locked by another transaction.
+func expireReservation(tx *sql.Tx, reservationID string, legacyTTL time.Duration) (*models.ExpiredReservation, []reservationRow, error) {
+	var locked, pending int
+	err := tx.QueryRow(`
+		WITH locked AS (
+			SELECT id FROM reservations
+			WHERE reservation_id = $1 AND status = 'pending'
+			ORDER BY id
+			FOR UPDATE SKIP LOCKED
+		)
+		SELECT (SELECT COUNT(*) FROM locked), (SELECT COUNT(*) FROM reservations WHERE reservation_id = $1 AND status = 'pending')
+	`, reservationID).Scan(&locked, &pending)
+	if err != nil {
+		return nil, nil, err
+	}
+	if locked == 0 || locked != pending {
+		return nil, nil, nil
+	}
+
+	rows, err := tx.Query(`
+		UPDATE reservations SET status = 'expired'
+		WHERE reservation_id = $1 AND status = 'pending'
+			AND COALESCE(expires_at, created_at + make_interval(secs => $2)) <= NOW()
+		RETURNING product_id, variant_id, location_id, quantity, COALESCE(expires_at, created_at + make_interval(secs => $2))
+	`, reservationID, legacyTTL.Seconds())
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	res := &models.ExpiredReservation{ReservationID: reservationID}
+	var released []reservationRow
+	for rows.Next() {
+		row := reservationRow{ReservationID: reservationID}
+		if err := rows.Scan(&row.ProductID, &row.VariantID, &row.LocationID, &row.Quantity, &res.ExpiresAt); err != nil {
+			return nil, nil, err
+		}
+		res.Items = addItem(res.Items, row)
+		released = append(released, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	if len(released) == 0 {
+		return nil, nil, nil
+	}
+	return res, released, nil
+}
+
+// addItem adds a reservation row to items, merging the parts of an item allocated
+// from different locations.
+func addItem(items []models.ReserveItem, row reservationRow) []models.ReserveItem {
+	for i := range items {
+		if items[i].ProductID == row.ProductID && items[i].VariantID == row.VariantID {
+			items[i].Quantity += row.Quantity
+			return items
+		}
+	}
+	return append(items, models.ReserveItem{ProductID: row.ProductID, VariantID: row.VariantID, Quantity: row.Quantity})
+}
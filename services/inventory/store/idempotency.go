@@ -0,0 +1,115 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/metalbear-co/metalmart/services/inventory/models"
+)
+
+// ErrIdempotencyConflict is returned when an idempotency key is reused with a
+// different request payload.
+var ErrIdempotencyConflict = errors.New("idempotency key was already used for a different request")
+
+// migrateIdempotency stores the outcome of reserve, confirm and release requests sent
+// with an Idempotency-Key. response is JSON rather than JSONB so replays are byte for
+// byte the original body. The key is claimed and its response saved in the same
+// transaction as the stock change. A request refused for lack of stock or an unknown
+// reservation records that refusal, undoing its work back to the savepoint taken after
+// the claim; one that fails on an internal error leaves nothing behind and can be
+// retried under the same key.
+func (s *PostgresStore) migrateIdempotency() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS idempotency_keys (
+		operation VARCHAR(20) NOT NULL,
+		idempotency_key VARCHAR(255) NOT NULL,
+		request_hash CHAR(64) NOT NULL,
+		reservation_id UUID,
+		status_code INT,
+		response JSON,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (operation, idempotency_key)
+	);
+	CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created_at ON idempotency_keys(created_at);
+	`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+// claimIdempotencyKey records key inside tx. When a committed request already holds
+// the key it returns that request's response instead; a concurrent request with the
+// same key blocks here until the first one commits or rolls back.
+func claimIdempotencyKey(tx *sql.Tx, key *models.IdempotencyKey) (*models.StoredResponse, error) {
+	if key == nil {
+		return nil, nil
+	}
+	res, err := tx.Exec(`
+		INSERT INTO idempotency_keys (operation, idempotency_key, request_hash) VALUES ($1, $2, $3)
+		ON CONFLICT (operation, idempotency_key) DO NOTHING
+	`, key.Operation, key.Key, key.RequestHash)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 1 {
+		_, err := tx.Exec(`SAVEPOINT idempotent_operation`)
+		return nil, err
+	}
+
+	var hash string
+	stored := &models.StoredResponse{}
+	err = tx.QueryRow(`
+		SELECT request_hash, status_code, response FROM idempotency_keys
+		WHERE operation = $1 AND idempotency_key = $2
+	`, key.Operation, key.Key).Scan(&hash, &stored.StatusCode, &stored.Body)
+	if err != nil {
+		return nil, err
+	}
+	if hash != key.RequestHash {
+		return nil, ErrIdempotencyConflict
+	}
+	return stored, nil
+}
+
+// saveIdempotentResponse records the response of the request that claimed key.
+func saveIdempotentResponse(tx *sql.Tx, key *models.IdempotencyKey, reservationID string, statusCode int, response interface{}) error {
+	if key == nil {
+		return nil
+	}
+	body, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		UPDATE idempotency_keys SET reservation_id = NULLIF($3, '')::uuid, status_code = $4, response = $5
+		WHERE operation = $1 AND idempotency_key = $2
+	`, key.Operation, key.Key, reservationID, statusCode, body)
+	return err
+}
+
+// refuseIdempotent records the refusal of the request that claimed key, undoing its
+// work back to the claim, and commits tx. Without a key it does nothing and the caller
+// rolls back.
+func refuseIdempotent(tx *sql.Tx, key *models.IdempotencyKey, reservationID string, statusCode int, response interface{}) error {
+	if key == nil {
+		return nil
+	}
+	if _, err := tx.Exec(`ROLLBACK TO SAVEPOINT idempotent_operation`); err != nil {
+		return err
+	}
+	if err := saveIdempotentResponse(tx, key, reservationID, statusCode, response); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// PurgeIdempotencyKeys deletes keys recorded before now minus retention, after which
+// a retry with the same key is treated as a new request.
+func (s *PostgresStore) PurgeIdempotencyKeys(retention time.Duration) (int64, error) {
+	res, err := s.db.Exec(`DELETE FROM idempotency_keys WHERE created_at < NOW() - make_interval(secs => $1)`, retention.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,389 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/metalbear-co/metalmart/services/inventory/models"
+)
+
+// ErrLocationNotFound is returned when stock is set at a location that does not exist.
+var ErrLocationNotFound = errors.New("location not found")
+
+// ErrStockNotFound is returned when a product or variant has no active stock record.
+var ErrStockNotFound = errors.New("not found in inventory")
+
+// ErrInsufficientStock is returned when the active locations cannot cover a reservation.
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// ErrReservationNotFound is returned when a reservation to release or confirm has no
+// pending rows.
+var ErrReservationNotFound = errors.New("reservation not found or already processed")
+
+// migrateLocations creates warehouse locations and moves stock to per-location rows in
+// stock_levels. The stock and reserved quantities on inventory become totals over all
+// locations, kept in step by refreshTotals. Stock recorded before locations existed,
+// and the reservations holding it, belong to the 'main' location (DefaultLocationID).
+func (s *PostgresStore) migrateLocations() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS locations (
+		id VARCHAR(50) PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		country CHAR(2) NOT NULL,
+		priority INTEGER NOT NULL DEFAULT 0,
+		active BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+	INSERT INTO locations (id, name, country) VALUES ('main', 'Main warehouse', 'US')
+	ON CONFLICT (id) DO NOTHING;
+
+	CREATE TABLE IF NOT EXISTS stock_levels (
+		product_id VARCHAR(50) NOT NULL,
+		variant_id VARCHAR(50) NOT NULL DEFAULT '',
+		location_id VARCHAR(50) NOT NULL REFERENCES locations(id),
+		stock_quantity INTEGER NOT NULL DEFAULT 0,
+		reserved_quantity INTEGER NOT NULL DEFAULT 0,
+		last_updated TIMESTAMP NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (product_id, variant_id, location_id)
+	);
+
+	INSERT INTO stock_levels (product_id, variant_id, location_id, stock_quantity, reserved_quantity)
+	SELECT i.product_id, i.variant_id, 'main', COALESCE(i.stock_quantity, 0), COALESCE(i.reserved_quantity, 0)
+	FROM inventory i
+	WHERE (COALESCE(i.stock_quantity, 0) <> 0 OR COALESCE(i.reserved_quantity, 0) <> 0)
+		AND NOT EXISTS (SELECT 1 FROM stock_levels l WHERE l.product_id = i.product_id AND l.variant_id = i.variant_id);
+
+	ALTER TABLE reservations ADD COLUMN IF NOT EXISTS location_id VARCHAR(50) NOT NULL DEFAULT 'main';
+	`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+const locationColumns = `id, name, country, priority, active, created_at`
+
+func scanLocation(row interface{ Scan(...interface{}) error }, l *models.Location) error {
+	return row.Scan(&l.ID, &l.Name, &l.Country, &l.Priority, &l.Active, &l.CreatedAt)
+}
+
+// ListLocations returns every location in allocation order.
+func (s *PostgresStore) ListLocations() ([]models.Location, error) {
+	rows, err := s.db.Query(`SELECT ` + locationColumns + ` FROM locations ORDER BY priority, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	locations := []models.Location{}
+	for rows.Next() {
+		var l models.Location
+		if err := scanLocation(rows, &l); err != nil {
+			return nil, err
+		}
+		locations = append(locations, l)
+	}
+	return locations, rows.Err()
+}
+
+// SetLocation creates the location id or replaces its details.
+func (s *PostgresStore) SetLocation(id string, in models.LocationInput) (*models.Location, error) {
+	var l models.Location
+	err := scanLocation(s.db.QueryRow(`
+		INSERT INTO locations (id, name, country, priority, active)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (id) DO UPDATE SET name = $2, country = $3, priority = $4, active = $5
+		RETURNING `+locationColumns+`
+	`, id, in.Name, in.Country, in.Priority, *in.Active), &l)
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// locationStock returns the per-location stock of a product or variant, or of all of
+// the product's active variants when variantID is nil.
+func (s *PostgresStore) locationStock(productID string, variantID *string) (map[string][]models.LocationStock, error) {
+	rows, err := s.db.Query(`
+		SELECT l.variant_id, l.location_id, l.stock_quantity, l.reserved_quantity
+		FROM stock_levels l
+		JOIN inventory i ON i.product_id = l.product_id AND i.variant_id = l.variant_id AND i.retired_at IS NULL
+		WHERE l.product_id = $1 AND ($2::text IS NULL OR l.variant_id = $2)
+		ORDER BY l.variant_id, l.location_id
+	`, productID, variantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	levels := map[string][]models.LocationStock{}
+	for rows.Next() {
+		var variant string
+		var level models.LocationStock
+		if err := rows.Scan(&variant, &level.LocationID, &level.StockQuantity, &level.ReservedQuantity); err != nil {
+			return nil, err
+		}
+		levels[variant] = append(levels[variant], level)
+	}
+	return levels, rows.Err()
+}
+
+// sumLocations adds up per-location stock of several variants by location.
+func sumLocations(levels map[string][]models.LocationStock) []models.LocationStock {
+	index := map[string]int{}
+	var total []models.LocationStock
+	for _, variantLevels := range levels {
+		for _, level := range variantLevels {
+			i, ok := index[level.LocationID]
+			if !ok {
+				i = len(total)
+				index[level.LocationID] = i
+				total = append(total, models.LocationStock{LocationID: level.LocationID})
+			}
+			total[i].StockQuantity += level.StockQuantity
+			total[i].ReservedQuantity += level.ReservedQuantity
+		}
+	}
+	sort.Slice(total, func(i, j int) bool { return total[i].LocationID < total[j].LocationID })
+	return total
+}
+
+// skuKey identifies a stock record: a product, or one of its variants.
+type skuKey struct {
+	ProductID string
+	VariantID string
+}
+
+func (k skuKey) String() string {
+	return models.ReserveItem{ProductID: k.ProductID, VariantID: k.VariantID}.String()
+}
+
+func sortSKUs(keys []skuKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].ProductID != keys[j].ProductID {
+			return keys[i].ProductID < keys[j].ProductID
+		}
+		return keys[i].VariantID < keys[j].VariantID
+	})
+}
+
+// lockSKUs locks the active inventory rows of keys in a fixed order, so writers that
+// touch several records cannot deadlock. Every write to stock_levels happens under the
+// lock of its inventory row.
+func lockSKUs(tx *sql.Tx, keys []skuKey) error {
+	sorted := append([]skuKey(nil), keys...)
+	sortSKUs(sorted)
+	for _, key := range sorted {
+		var id string
+		err := tx.QueryRow(`
+			SELECT id FROM inventory
+			WHERE product_id = $1 AND variant_id = $2 AND retired_at IS NULL
+			FOR UPDATE
+		`, key.ProductID, key.VariantID).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s %w", key, ErrStockNotFound)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshTotals sets the inventory totals of a stock record to the sum of its stock
+// levels and, for an active record, updates its stock state.
+func refreshTotals(tx *sql.Tx, key skuKey) error {
+	var available, threshold int
+	var state sql.NullString
+	var retired bool
+	err := tx.QueryRow(`
+		UPDATE inventory i
+		SET stock_quantity = COALESCE(t.stock, 0), reserved_quantity = COALESCE(t.reserved, 0), last_updated = NOW()
+		FROM (
+			SELECT SUM(stock_quantity) AS stock, SUM(reserved_quantity) AS reserved
+			FROM stock_levels WHERE product_id = $1 AND variant_id = $2
+		) t
+		WHERE i.product_id = $1 AND i.variant_id = $2
+		RETURNING i.stock_quantity - i.reserved_quantity, i.stock_state, i.retired_at IS NOT NULL,
+			COALESCE((SELECT threshold FROM reorder_thresholds WHERE product_id = $1), $3)
+	`, key.ProductID, key.VariantID, models.DefaultReorderThreshold).Scan(&available, &state, &retired, &threshold)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && retired) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return updateStockState(tx, key, state, available, threshold)
+}
+
+// rankLocations orders locations best first for shipping to country, returning their
+// ids: those in the country, then by ascending priority, then by id.
+func rankLocations(locations []models.Location, country string) []string {
+	sorted := append([]models.Location(nil), locations...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if (a.Country == country) != (b.Country == country) {
+			return a.Country == country
+		}
+		if a.Priority != b.Priority {
+			return a.Priority < b.Priority
+		}
+		return a.ID < b.ID
+	})
+	ids := make([]string, len(sorted))
+	for i, l := range sorted {
+		ids[i] = l.ID
+	}
+	return ids
+}
+
+// allocate picks the locations items are reserved from. locations are the active
+// locations best first, and available the unreserved stock of each record at each of
+// them. With AllocateSingleLocation the first location that can ship every item takes
+// the whole order; otherwise, or with AllocateNearest, each item is filled from the
+// best locations that have it.
+func allocate(items []models.ReserveItem, strategy string, locations []string, available map[skuKey]map[string]int) ([]models.Allocation, error) {
+	var order []skuKey
+	demand := map[skuKey]int{}
+	for _, item := range items {
+		key := skuKey{item.ProductID, item.VariantID}
+		if _, ok := demand[key]; !ok {
+			order = append(order, key)
+		}
+		demand[key] += item.Quantity
+	}
+
+	for _, key := range order {
+		total := 0
+		for _, location := range locations {
+			if n := available[key][location]; n > 0 {
+				total += n
+			}
+		}
+		if total < demand[key] {
+			return nil, fmt.Errorf("%w for %s: available %d, requested %d", ErrInsufficientStock, key, total, demand[key])
+		}
+	}
+
+	if strategy == models.AllocateSingleLocation {
+		for _, location := range locations {
+			fits := true
+			for _, key := range order {
+				if available[key][location] < demand[key] {
+					fits = false
+					break
+				}
+			}
+			if !fits {
+				continue
+			}
+			allocations := make([]models.Allocation, len(order))
+			for i, key := range order {
+				allocations[i] = models.Allocation{ProductID: key.ProductID, VariantID: key.VariantID, LocationID: location, Quantity: demand[key]}
+			}
+			return allocations, nil
+		}
+	}
+
+	var allocations []models.Allocation
+	for _, key := range order {
+		remaining := demand[key]
+		for _, location := range locations {
+			if remaining == 0 {
+				break
+			}
+			take := available[key][location]
+			if take > remaining {
+				take = remaining
+			}
+			if take <= 0 {
+				continue
+			}
+			allocations = append(allocations, models.Allocation{ProductID: key.ProductID, VariantID: key.VariantID, LocationID: location, Quantity: take})
+			remaining -= take
+		}
+	}
+	return allocations, nil
+}
+
+// reservationRow is the part of a reservation held at one location.
+type reservationRow struct {
+	skuKey
+	ReservationID string
+	LocationID    string
+	Quantity      int
+}
+
+// pendingReservation locks the pending rows of a reservation.
+func pendingReservation(tx *sql.Tx, reservationID string) ([]reservationRow, error) {
+	rows, err := tx.Query(`
+		SELECT reservation_id, product_id, variant_id, location_id, quantity FROM reservations
+		WHERE reservation_id = $1 AND status = 'pending'
+		ORDER BY id
+		FOR UPDATE
+	`, reservationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var found []reservationRow
+	for rows.Next() {
+		var row reservationRow
+		if err := rows.Scan(&row.ReservationID, &row.ProductID, &row.VariantID, &row.LocationID, &row.Quantity); err != nil {
+			return nil, err
+		}
+		found = append(found, row)
+	}
+	return found, rows.Err()
+}
+
+// settleReservations gives back the stock held by reservation rows being released or
+// expired, or for a confirmation, takes it out of stock. Each row is recorded in the
+// ledger as a movement of m's type, reason, actor and reference. The rows must already
+// be locked.
+func settleReservations(tx *sql.Tx, rows []reservationRow, m models.Movement) error {
+	var keys []skuKey
+	seen := map[skuKey]bool{}
+	for _, row := range rows {
+		if !seen[row.skuKey] {
+			seen[row.skuKey] = true
+			keys = append(keys, row.skuKey)
+		}
+	}
+	sortSKUs(keys)
+	// Retired records still hold the reservations made before they were retired, so
+	// lock them regardless of retired_at.
+	for _, key := range keys {
+		if _, err := tx.Exec(`SELECT 1 FROM inventory WHERE product_id = $1 AND variant_id = $2 FOR UPDATE`, key.ProductID, key.VariantID); err != nil {
+			return err
+		}
+	}
+
+	for _, row := range rows {
+		m.ProductID, m.VariantID, m.LocationID = row.ProductID, row.VariantID, row.LocationID
+		m.ReservationID = row.ReservationID
+		m.ReservedDelta = -row.Quantity
+		m.StockDelta = 0
+		if m.Type == models.MovementConfirmation {
+			m.StockDelta = -row.Quantity
+		}
+		_, err := tx.Exec(`
+			UPDATE stock_levels
+			SET stock_quantity = stock_quantity + $1, reserved_quantity = reserved_quantity + $2, last_updated = NOW()
+			WHERE product_id = $3 AND variant_id = $4 AND location_id = $5
+		`, m.StockDelta, m.ReservedDelta, row.ProductID, row.VariantID, row.LocationID)
+		if err != nil {
+			return err
+		}
+		if err := recordMovement(tx, &m); err != nil {
+			return err
+		}
+	}
+	for _, key := range keys {
+		if err := refreshTotals(tx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package store
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/metalbear-co/metalmart/services/inventory/models"
+)
+
+func TestRankLocations(t *testing.T) {
+	locations := []models.Location{
+		{ID: "main", Country: "US", Priority: 0},
+		{ID: "berlin", Country: "DE", Priority: 1},
+		{ID: "hamburg", Country: "DE", Priority: 1},
+		{ID: "leeds", Country: "GB", Priority: 2},
+		{ID: "austin", Country: "US", Priority: 3},
+	}
+
+	tests := []struct {
+		name    string
+		country string
+		want    []string
+	}{
+		{"no country ranks by priority then id", "", []string{"main", "berlin", "hamburg", "leeds", "austin"}},
+		{"shipping country first", "DE", []string{"berlin", "hamburg", "main", "leeds", "austin"}},
+		{"shipping country by priority", "US", []string{"main", "austin", "berlin", "hamburg", "leeds"}},
+		{"country without locations", "FR", []string{"main", "berlin", "hamburg", "leeds", "austin"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rankLocations(locations, tt.country); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rankLocations(%q) = %v, want %v", tt.country, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	mug := skuKey{ProductID: "mug"}
+	shirtM := skuKey{ProductID: "shirt", VariantID: "m"}
+	locations := []string{"near", "far"}
+
+	tests := []struct {
+		name      string
+		items     []models.ReserveItem
+		strategy  string
+		available map[skuKey]map[string]int
+		want      []models.Allocation
+		wantErr   string
+	}{
+		{
+			name:      "single location prefers the best location that has everything",
+			items:     []models.ReserveItem{{ProductID: "mug", Quantity: 2}, {ProductID: "shirt", VariantID: "m", Quantity: 1}},
+			strategy:  models.AllocateSingleLocation,
+			available: map[skuKey]map[string]int{mug: {"near": 5, "far": 5}, shirtM: {"near": 1, "far": 3}},
+			want: []models.Allocation{
+				{ProductID: "mug", LocationID: "near", Quantity: 2},
+				{ProductID: "shirt", VariantID: "m", LocationID: "near", Quantity: 1},
+			},
+		},
+		{
+			name:      "single location skips a nearer location that would split the order",
+			items:     []models.ReserveItem{{ProductID: "mug", Quantity: 2}, {ProductID: "shirt", VariantID: "m", Quantity: 2}},
+			strategy:  models.AllocateSingleLocation,
+			available: map[skuKey]map[string]int{mug: {"near": 5, "far": 5}, shirtM: {"near": 1, "far": 3}},
+			want: []models.Allocation{
+				{ProductID: "mug", LocationID: "far", Quantity: 2},
+				{ProductID: "shirt", VariantID: "m", LocationID: "far", Quantity: 2},
+			},
+		},
+		{
+			name:      "single location splits when no location has everything",
+			items:     []models.ReserveItem{{ProductID: "mug", Quantity: 4}},
+			strategy:  models.AllocateSingleLocation,
+			available: map[skuKey]map[string]int{mug: {"near": 3, "far": 2}},
+			want: []models.Allocation{
+				{ProductID: "mug", LocationID: "near", Quantity: 3},
+				{ProductID: "mug", LocationID: "far", Quantity: 1},
+			},
+		},
+		{
+			name:      "nearest fills each item from the best locations",
+			items:     []models.ReserveItem{{ProductID: "mug", Quantity: 2}, {ProductID: "shirt", VariantID: "m", Quantity: 2}},
+			strategy:  models.AllocateNearest,
+			available: map[skuKey]map[string]int{mug: {"near": 5, "far": 5}, shirtM: {"near": 1, "far": 3}},
+			want: []models.Allocation{
+				{ProductID: "mug", LocationID: "near", Quantity: 2},
+				{ProductID: "shirt", VariantID: "m", LocationID: "near", Quantity: 1},
+				{ProductID: "shirt", VariantID: "m", LocationID: "far", Quantity: 1},
+			},
+		},
+		{
+			name:      "repeated items are merged",
+			items:     []models.ReserveItem{{ProductID: "mug", Quantity: 1}, {ProductID: "mug", Quantity: 2}},
+			strategy:  models.AllocateNearest,
+			available: map[skuKey]map[string]int{mug: {"near": 3}},
+			want:      []models.Allocation{{ProductID: "mug", LocationID: "near", Quantity: 3}},
+		},
+		{
+			name:      "locations that are not ranked are not allocated from",
+			items:     []models.ReserveItem{{ProductID: "mug", Quantity: 2}},
+			strategy:  models.AllocateNearest,
+			available: map[skuKey]map[string]int{mug: {"near": 1, "inactive": 5}},
+			wantErr:   "insufficient stock for product mug: available 1, requested 2",
+		},
+		{
+			name:      "oversold locations do not count against others",
+			items:     []models.ReserveItem{{ProductID: "mug", Quantity: 2}},
+			strategy:  models.AllocateNearest,
+			available: map[skuKey]map[string]int{mug: {"near": -1, "far": 2}},
+			want:      []models.Allocation{{ProductID: "mug", LocationID: "far", Quantity: 2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := allocate(tt.items, tt.strategy, locations, tt.available)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("allocate() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("allocate() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("allocate() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
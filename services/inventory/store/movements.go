@@ -0,0 +1,259 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/metalbear-co/metalmart/services/inventory/models"
+)
+
+// ErrStockBelowReserved is returned when a movement would leave a location with less
+// stock than is reserved there.
+var ErrStockBelowReserved = errors.New("movement would leave less stock than is reserved")
+
+// migrateMovements creates the stock ledger. Every change to a stock level is recorded
+// as a movement in the same transaction, so the ledger sums to the levels; a trigger
+// rejects updates and deletes. Levels that predate the ledger get an opening balance.
+func (s *PostgresStore) migrateMovements() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS stock_movements (
+		id BIGSERIAL PRIMARY KEY,
+		product_id VARCHAR(50) NOT NULL,
+		variant_id VARCHAR(50) NOT NULL DEFAULT '',
+		location_id VARCHAR(50) NOT NULL REFERENCES locations(id),
+		type VARCHAR(20) NOT NULL,
+		stock_delta INTEGER NOT NULL DEFAULT 0,
+		reserved_delta INTEGER NOT NULL DEFAULT 0,
+		reason VARCHAR(500) NOT NULL DEFAULT '',
+		actor VARCHAR(100) NOT NULL DEFAULT '',
+		reservation_id UUID,
+		reference VARCHAR(255) NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_stock_movements_product ON stock_movements(product_id, id);
+	CREATE INDEX IF NOT EXISTS idx_stock_movements_reservation ON stock_movements(reservation_id) WHERE reservation_id IS NOT NULL;
+
+	CREATE OR REPLACE FUNCTION stock_movements_append_only() RETURNS trigger AS $$
+	BEGIN
+		RAISE EXCEPTION 'stock_movements is append-only';
+	END;
+	$$ LANGUAGE plpgsql;
+	DROP TRIGGER IF EXISTS stock_movements_append_only ON stock_movements;
+	CREATE TRIGGER stock_movements_append_only BEFORE UPDATE OR DELETE ON stock_movements
+		FOR EACH ROW EXECUTE FUNCTION stock_movements_append_only();
+
+	INSERT INTO stock_movements (product_id, variant_id, location_id, type, stock_delta, reserved_delta, reason, actor)
+	SELECT l.product_id, l.variant_id, l.location_id, 'adjustment', l.stock_quantity, l.reserved_quantity, 'opening balance', 'migration'
+	FROM stock_levels l
+	WHERE (l.stock_quantity <> 0 OR l.reserved_quantity <> 0)
+		AND NOT EXISTS (
+			SELECT 1 FROM stock_movements m
+			WHERE m.product_id = l.product_id AND m.variant_id = l.variant_id AND m.location_id = l.location_id
+		);
+	`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+// recordMovement appends m to the ledger, setting its id and creation time.
+func recordMovement(tx *sql.Tx, m *models.Movement) error {
+	return tx.QueryRow(`
+		INSERT INTO stock_movements (product_id, variant_id, location_id, type, stock_delta, reserved_delta, reason, actor, reservation_id, reference)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, '')::uuid, $10)
+		RETURNING id, created_at
+	`, m.ProductID, m.VariantID, m.LocationID, m.Type, m.StockDelta, m.ReservedDelta, m.Reason, m.Actor, m.ReservationID, m.Reference).Scan(&m.ID, &m.CreatedAt)
+}
+
+// ListMovements returns a page of a product's ledger, newest first.
+func (s *PostgresStore) ListMovements(productID string, f models.MovementFilter) (*models.MovementPage, error) {
+	where := []string{"product_id = $1"}
+	args := []interface{}{productID}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if f.VariantID != nil {
+		add("variant_id = $%d", *f.VariantID)
+	}
+	if f.LocationID != "" {
+		add("location_id = $%d", f.LocationID)
+	}
+	if f.Type != "" {
+		add("type = $%d", f.Type)
+	}
+	if f.Before > 0 {
+		add("id < $%d", f.Before)
+	}
+	args = append(args, f.Limit+1)
+
+	rows, err := s.db.Query(`
+		SELECT id, product_id, variant_id, location_id, type, stock_delta, reserved_delta, reason, actor, COALESCE(reservation_id::text, ''), reference, created_at
+		FROM stock_movements
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY id DESC
+		LIMIT $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &models.MovementPage{Movements: []models.Movement{}}
+	for rows.Next() {
+		var m models.Movement
+		err := rows.Scan(&m.ID, &m.ProductID, &m.VariantID, &m.LocationID, &m.Type, &m.StockDelta, &m.ReservedDelta,
+			&m.Reason, &m.Actor, &m.ReservationID, &m.Reference, &m.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		page.Movements = append(page.Movements, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(page.Movements) > f.Limit {
+		page.Movements = page.Movements[:f.Limit]
+		page.NextCursor = strconv.FormatInt(page.Movements[f.Limit-1].ID, 10)
+	}
+	return page, nil
+}
+
+// RecordMovement applies a receipt, adjustment or correction to the stock on hand of
+// a product or variant at a location and records it in the ledger.
+func (s *PostgresStore) RecordMovement(productID string, in models.MovementInput, actor string) (*models.Movement, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	key := skuKey{productID, in.VariantID}
+	if err := lockSKUs(tx, []skuKey{key}); err != nil {
+		return nil, err
+	}
+
+	var stock, reserved int
+	err = tx.QueryRow(`
+		INSERT INTO stock_levels (product_id, variant_id, location_id, stock_quantity, reserved_quantity)
+		VALUES ($1, $2, $3, $4, 0)
+		ON CONFLICT (product_id, variant_id, location_id) DO UPDATE
+		SET stock_quantity = stock_levels.stock_quantity + $4, last_updated = NOW()
+		RETURNING stock_quantity, reserved_quantity
+	`, productID, in.VariantID, in.LocationID, in.Quantity).Scan(&stock, &reserved)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return nil, ErrLocationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if stock < reserved {
+		return nil, ErrStockBelowReserved
+	}
+
+	m := models.Movement{
+		ProductID:  productID,
+		VariantID:  in.VariantID,
+		LocationID: in.LocationID,
+		Type:       in.Type,
+		StockDelta: in.Quantity,
+		Reason:     in.Reason,
+		Actor:      actor,
+		Reference:  in.Reference,
+	}
+	if err := recordMovement(tx, &m); err != nil {
+		return nil, err
+	}
+	if err := refreshTotals(tx, key); err != nil {
+		return nil, err
+	}
+	return &m, tx.Commit()
+}
+
+// Reconcile compares a product's stock levels with the sums of its ledger. With
+// rebuild it resets every differing level, and the product's totals, to the ledger's
+// quantities.
+func (s *PostgresStore) Reconcile(productID string, rebuild bool) (*models.Reconciliation, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT variant_id FROM inventory WHERE product_id = $1 ORDER BY variant_id FOR UPDATE`, productID)
+	if err != nil {
+		return nil, err
+	}
+	var variants []string
+	for rows.Next() {
+		var variant string
+		if err := rows.Scan(&variant); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		variants = append(variants, variant)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+	if len(variants) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	rows, err = tx.Query(`
+		SELECT COALESCE(l.variant_id, m.variant_id), COALESCE(l.location_id, m.location_id),
+			COALESCE(l.stock_quantity, 0), COALESCE(l.reserved_quantity, 0), COALESCE(m.stock, 0), COALESCE(m.reserved, 0)
+		FROM (SELECT * FROM stock_levels WHERE product_id = $1) l
+		FULL JOIN (
+			SELECT variant_id, location_id, SUM(stock_delta) AS stock, SUM(reserved_delta) AS reserved
+			FROM stock_movements WHERE product_id = $1
+			GROUP BY variant_id, location_id
+		) m ON m.variant_id = l.variant_id AND m.location_id = l.location_id
+		WHERE COALESCE(l.stock_quantity, 0) <> COALESCE(m.stock, 0) OR COALESCE(l.reserved_quantity, 0) <> COALESCE(m.reserved, 0)
+		ORDER BY 1, 2
+	`, productID)
+	if err != nil {
+		return nil, err
+	}
+	result := &models.Reconciliation{ProductID: productID, Discrepancies: []models.StockDiscrepancy{}}
+	for rows.Next() {
+		d := models.StockDiscrepancy{ProductID: productID}
+		if err := rows.Scan(&d.VariantID, &d.LocationID, &d.StockQuantity, &d.ReservedQuantity, &d.LedgerStock, &d.LedgerReserved); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		result.Discrepancies = append(result.Discrepancies, d)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+	if !rebuild {
+		return result, nil
+	}
+
+	for _, d := range result.Discrepancies {
+		_, err := tx.Exec(`
+			INSERT INTO stock_levels (product_id, variant_id, location_id, stock_quantity, reserved_quantity)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (product_id, variant_id, location_id) DO UPDATE
+			SET stock_quantity = $4, reserved_quantity = $5, last_updated = NOW()
+		`, productID, d.VariantID, d.LocationID, d.LedgerStock, d.LedgerReserved)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, variant := range variants {
+		if err := refreshTotals(tx, skuKey{productID, variant}); err != nil {
+			return nil, err
+		}
+	}
+	result.Rebuilt = true
+	return result, tx.Commit()
+}
@@ -3,12 +3,15 @@ package store
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/metalbear-co/metalmart/services/inventory/models"
 )
 
@@ -37,7 +40,8 @@ func (s *PostgresStore) Migrate() error {
 	query := `
 	CREATE TABLE IF NOT EXISTS inventory (
 		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-		product_id VARCHAR(50) NOT NULL UNIQUE,
+		product_id VARCHAR(50) NOT NULL,
+		variant_id VARCHAR(50) NOT NULL DEFAULT '',
 		stock_quantity INTEGER DEFAULT 0,
 		reserved_quantity INTEGER DEFAULT 0,
 		last_updated TIMESTAMP DEFAULT NOW()
@@ -48,6 +52,7 @@ func (s *PostgresStore) Migrate() error {
 		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
 		reservation_id UUID NOT NULL,
 		product_id VARCHAR(50) NOT NULL,
+		variant_id VARCHAR(50) NOT NULL DEFAULT '',
 		quantity INTEGER NOT NULL,
 		status VARCHAR(20) DEFAULT 'pending',
 		created_at TIMESTAMP DEFAULT NOW()
@@ -55,8 +60,38 @@ func (s *PostgresStore) Migrate() error {
 	CREATE INDEX IF NOT EXISTS idx_reservations_status ON reservations(status);
 	CREATE INDEX IF NOT EXISTS idx_reservations_reservation_id ON reservations(reservation_id);
 	`
-	_, err := s.db.Exec(query)
-	return err
+	if _, err := s.db.Exec(query); err != nil {
+		return err
+	}
+
+	// Stock is tracked per (product, variant); '' is the product itself. Tables created
+	// before variants existed had a unique constraint on product_id alone.
+	query = `
+	ALTER TABLE inventory ADD COLUMN IF NOT EXISTS variant_id VARCHAR(50) NOT NULL DEFAULT '';
+	ALTER TABLE inventory DROP CONSTRAINT IF EXISTS inventory_product_id_key;
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_inventory_product_variant ON inventory(product_id, variant_id);
+	ALTER TABLE reservations ADD COLUMN IF NOT EXISTS variant_id VARCHAR(50) NOT NULL DEFAULT '';
+	`
+	if _, err := s.db.Exec(query); err != nil {
+		return err
+	}
+
+	if err := s.migrateCatalogueSync(); err != nil {
+		return err
+	}
+	if err := s.migrateReservationExpiry(); err != nil {
+		return err
+	}
+	if err := s.migrateIdempotency(); err != nil {
+		return err
+	}
+	if err := s.migrateLocations(); err != nil {
+		return err
+	}
+	if err := s.migrateMovements(); err != nil {
+		return err
+	}
+	return s.migrateStockAlerts()
 }
 
 func (s *PostgresStore) SeedFromCatalogue(catalogueURL string) error {
@@ -67,194 +102,436 @@ func (s *PostgresStore) SeedFromCatalogue(catalogueURL string) error {
 		return nil
 	}
 
-	resp, err := http.Get(catalogueURL + "/api/products")
-	if err != nil {
-		return fmt.Errorf("failed to fetch catalogue: %w", err)
-	}
-	defer resp.Body.Close()
-
 	var products []struct {
 		ID string `json:"id"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&products); err != nil {
-		return fmt.Errorf("failed to decode products: %w", err)
+	cursor := ""
+	for {
+		pageURL := catalogueURL + "/api/products?limit=200"
+		if cursor != "" {
+			pageURL += "&cursor=" + url.QueryEscape(cursor)
+		}
+		resp, err := http.Get(pageURL)
+		if err != nil {
+			return fmt.Errorf("failed to fetch catalogue: %w", err)
+		}
+
+		var page struct {
+			Products []struct {
+				ID string `json:"id"`
+			} `json:"products"`
+			NextCursor string `json:"next_cursor"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to decode products: %w", err)
+		}
+
+		products = append(products, page.Products...)
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
 	}
 
 	for _, p := range products {
-		s.InitInventory(p.ID, 100)
+		s.InitInventory(models.InitInventoryRequest{
+			ProductID:  p.ID,
+			LocationID: models.DefaultLocationID,
+			Quantity:   100,
+			Reason:     "seeded from catalogue",
+		}, "seed")
 	}
 	log.Printf("Seeded inventory with %d products", len(products))
 	return nil
 }
 
-func (s *PostgresStore) GetInventory(productID string) (*models.Inventory, error) {
-	var inv models.Inventory
-	err := s.db.QueryRow(`
-		SELECT id, product_id, stock_quantity, reserved_quantity, last_updated
+// GetInventory returns the stock of a variant, or when variantID is empty, of the product
+// summed over all of its variants, each broken down by location.
+func (s *PostgresStore) GetInventory(productID, variantID string) (*models.Inventory, error) {
+	if variantID != "" {
+		var inv models.Inventory
+		err := s.db.QueryRow(`
+			SELECT id, product_id, variant_id, stock_quantity, reserved_quantity, last_updated
+			FROM inventory
+			WHERE product_id = $1 AND variant_id = $2 AND retired_at IS NULL
+		`, productID, variantID).Scan(&inv.ID, &inv.ProductID, &inv.VariantID, &inv.StockQuantity, &inv.ReservedQuantity, &inv.LastUpdated)
+		if err != nil {
+			return nil, err
+		}
+		levels, err := s.locationStock(productID, &variantID)
+		if err != nil {
+			return nil, err
+		}
+		inv.Locations = nonNilLocations(levels[variantID])
+		if inv.ReorderThreshold, err = s.reorderThreshold(productID); err != nil {
+			return nil, err
+		}
+		inv.StockState = models.StockState(inv.StockQuantity-inv.ReservedQuantity, inv.ReorderThreshold)
+		return &inv, nil
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, product_id, variant_id, stock_quantity, reserved_quantity, last_updated
 		FROM inventory
-		WHERE product_id = $1
-	`, productID).Scan(&inv.ID, &inv.ProductID, &inv.StockQuantity, &inv.ReservedQuantity, &inv.LastUpdated)
+		WHERE product_id = $1 AND retired_at IS NULL
+		ORDER BY variant_id
+	`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rowsFound []models.Inventory
+	for rows.Next() {
+		var inv models.Inventory
+		if err := rows.Scan(&inv.ID, &inv.ProductID, &inv.VariantID, &inv.StockQuantity, &inv.ReservedQuantity, &inv.LastUpdated); err != nil {
+			return nil, err
+		}
+		rowsFound = append(rowsFound, inv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(rowsFound) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	levels, err := s.locationStock(productID, nil)
 	if err != nil {
 		return nil, err
 	}
-	return &inv, nil
+	threshold, err := s.reorderThreshold(productID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range rowsFound {
+		inv := &rowsFound[i]
+		inv.Locations = nonNilLocations(levels[inv.VariantID])
+		inv.ReorderThreshold = threshold
+		inv.StockState = models.StockState(inv.StockQuantity-inv.ReservedQuantity, threshold)
+	}
+	if len(rowsFound) == 1 && rowsFound[0].VariantID == "" {
+		return &rowsFound[0], nil
+	}
+
+	total := models.Inventory{ProductID: productID, ReorderThreshold: threshold, Locations: nonNilLocations(sumLocations(levels))}
+	for _, inv := range rowsFound {
+		total.StockQuantity += inv.StockQuantity
+		total.ReservedQuantity += inv.ReservedQuantity
+		if inv.LastUpdated.After(total.LastUpdated) {
+			total.LastUpdated = inv.LastUpdated
+		}
+		if inv.VariantID != "" {
+			total.Variants = append(total.Variants, inv)
+		}
+	}
+	total.StockState = models.StockState(total.StockQuantity-total.ReservedQuantity, threshold)
+	return &total, nil
 }
 
-func (s *PostgresStore) InitInventory(productID string, quantity int) error {
-	_, err := s.db.Exec(`
-		INSERT INTO inventory (product_id, stock_quantity, reserved_quantity)
-		VALUES ($1, $2, 0)
-		ON CONFLICT (product_id) DO UPDATE SET stock_quantity = $2, last_updated = NOW()
-	`, productID, quantity)
-	return err
+func nonNilLocations(levels []models.LocationStock) []models.LocationStock {
+	if levels == nil {
+		return []models.LocationStock{}
+	}
+	return levels
 }
 
-func (s *PostgresStore) Reserve(items []models.ReserveItem) (string, error) {
+// InitInventory sets the stock of a product or variant at a location, creating or
+// restoring its stock record, and records the difference in the ledger.
+func (s *PostgresStore) InitInventory(req models.InitInventoryRequest, actor string) error {
 	tx, err := s.db.Begin()
 	if err != nil {
-		return "", err
+		return err
 	}
 	defer tx.Rollback()
 
-	reservationID := uuid.New().String()
+	_, err = tx.Exec(`
+		INSERT INTO inventory (product_id, variant_id, stock_quantity, reserved_quantity)
+		VALUES ($1, $2, 0, 0)
+		ON CONFLICT (product_id, variant_id) DO UPDATE SET retired_at = NULL, last_updated = NOW()
+	`, req.ProductID, req.VariantID)
+	if err != nil {
+		return err
+	}
+	key := skuKey{req.ProductID, req.VariantID}
+	if err := lockSKUs(tx, []skuKey{key}); err != nil {
+		return err
+	}
 
-	for _, item := range items {
-		var available int
-		err := tx.QueryRow(`
-			SELECT stock_quantity - reserved_quantity
-			FROM inventory
-			WHERE product_id = $1
-			FOR UPDATE
-		`, item.ProductID).Scan(&available)
-		if err != nil {
-			return "", fmt.Errorf("product %s not found in inventory", item.ProductID)
+	m := models.Movement{
+		ProductID:  req.ProductID,
+		VariantID:  req.VariantID,
+		LocationID: req.LocationID,
+		Type:       models.MovementReceipt,
+		StockDelta: req.Quantity,
+		Reason:     req.Reason,
+		Actor:      actor,
+	}
+	var previous int
+	err = tx.QueryRow(`
+		SELECT stock_quantity FROM stock_levels WHERE product_id = $1 AND variant_id = $2 AND location_id = $3
+	`, req.ProductID, req.VariantID, req.LocationID).Scan(&previous)
+	if err == nil {
+		m.Type = models.MovementAdjustment
+		m.StockDelta = req.Quantity - previous
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO stock_levels (product_id, variant_id, location_id, stock_quantity, reserved_quantity)
+		VALUES ($1, $2, $3, $4, 0)
+		ON CONFLICT (product_id, variant_id, location_id) DO UPDATE SET stock_quantity = $4, last_updated = NOW()
+	`, req.ProductID, req.VariantID, req.LocationID, req.Quantity)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return ErrLocationNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if m.StockDelta != 0 {
+		if err := recordMovement(tx, &m); err != nil {
+			return err
 		}
+	}
+	if err := refreshTotals(tx, key); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
 
-		if available < item.Quantity {
-			return "", fmt.Errorf("insufficient stock for product %s: available %d, requested %d", item.ProductID, available, item.Quantity)
+// Reserve holds stock for the request's items until it is confirmed or released, or
+// until ttl passes and the reaper releases it. Items are allocated to active locations
+// by the request's strategy, and a reservation row is recorded per item and location.
+// With a key that an earlier reservation already used, it reserves nothing and returns
+// that reservation's response to replay instead.
+func (s *PostgresStore) Reserve(req models.ReserveRequest, ttl time.Duration, key *models.IdempotencyKey, actor string) (*models.ReserveResponse, *models.StoredResponse, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	if replay, err := claimIdempotencyKey(tx, key); err != nil || replay != nil {
+		return nil, replay, err
+	}
+
+	reservationID := uuid.New().String()
+	var expiresAt time.Time
+	if err := tx.QueryRow(`SELECT NOW() + make_interval(secs => $1)`, ttl.Seconds()).Scan(&expiresAt); err != nil {
+		return nil, nil, err
+	}
+
+	var keys []skuKey
+	for _, item := range req.Items {
+		keys = append(keys, skuKey{item.ProductID, item.VariantID})
+	}
+	if err := lockSKUs(tx, keys); err != nil {
+		return nil, nil, refuseReservation(tx, key, err)
+	}
+
+	active, err := activeLocations(tx)
+	if err != nil {
+		return nil, nil, err
+	}
+	locations := rankLocations(active, req.ShippingCountry)
+	available := map[skuKey]map[string]int{}
+	for _, key := range keys {
+		if available[key] != nil {
+			continue
+		}
+		if available[key], err = availableStock(tx, key); err != nil {
+			return nil, nil, err
 		}
+	}
 
+	allocations, err := allocate(req.Items, req.Strategy, locations, available)
+	if err != nil {
+		return nil, nil, refuseReservation(tx, key, err)
+	}
+
+	for _, a := range allocations {
 		_, err = tx.Exec(`
-			UPDATE inventory
+			UPDATE stock_levels
 			SET reserved_quantity = reserved_quantity + $1, last_updated = NOW()
-			WHERE product_id = $2
-		`, item.Quantity, item.ProductID)
+			WHERE product_id = $2 AND variant_id = $3 AND location_id = $4
+		`, a.Quantity, a.ProductID, a.VariantID, a.LocationID)
 		if err != nil {
-			return "", err
+			return nil, nil, err
 		}
 
 		_, err = tx.Exec(`
-			INSERT INTO reservations (reservation_id, product_id, quantity, status)
-			VALUES ($1, $2, $3, 'pending')
-		`, reservationID, item.ProductID, item.Quantity)
+			INSERT INTO reservations (reservation_id, product_id, variant_id, location_id, quantity, status, expires_at)
+			VALUES ($1, $2, $3, $4, $5, 'pending', $6)
+		`, reservationID, a.ProductID, a.VariantID, a.LocationID, a.Quantity, expiresAt)
 		if err != nil {
-			return "", err
+			return nil, nil, err
+		}
+
+		err = recordMovement(tx, &models.Movement{
+			ProductID:     a.ProductID,
+			VariantID:     a.VariantID,
+			LocationID:    a.LocationID,
+			Type:          models.MovementReservation,
+			ReservedDelta: a.Quantity,
+			Actor:         actor,
+			ReservationID: reservationID,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, key := range keys {
+		if err := refreshTotals(tx, key); err != nil {
+			return nil, nil, err
 		}
 	}
 
+	resp := &models.ReserveResponse{ReservationID: reservationID, ExpiresAt: &expiresAt, Allocations: allocations, Success: true}
+	if err := saveIdempotentResponse(tx, key, reservationID, http.StatusOK, resp); err != nil {
+		return nil, nil, err
+	}
 	if err := tx.Commit(); err != nil {
-		return "", err
+		return nil, nil, err
 	}
 
-	return reservationID, nil
+	return resp, nil, nil
 }
 
-func (s *PostgresStore) Release(reservationID string) error {
-	tx, err := s.db.Begin()
-	if err != nil {
+// refuseReservation records a reservation refused for lack of stock under key before
+// returning err; other errors are returned as they are.
+func refuseReservation(tx *sql.Tx, key *models.IdempotencyKey, err error) error {
+	if !errors.Is(err, ErrStockNotFound) && !errors.Is(err, ErrInsufficientStock) {
 		return err
 	}
-	defer tx.Rollback()
+	resp := models.ReserveResponse{Success: false, Message: err.Error()}
+	if ferr := refuseIdempotent(tx, key, "", http.StatusConflict, resp); ferr != nil {
+		return ferr
+	}
+	return err
+}
 
-	rows, err := tx.Query(`
-		SELECT product_id, quantity FROM reservations
-		WHERE reservation_id = $1 AND status = 'pending'
-	`, reservationID)
+// activeLocations returns the locations reservations can be allocated from.
+func activeLocations(tx *sql.Tx) ([]models.Location, error) {
+	rows, err := tx.Query(`SELECT ` + locationColumns + ` FROM locations WHERE active`)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	var items []models.ReserveItem
+	var locations []models.Location
 	for rows.Next() {
-		var item models.ReserveItem
-		if err := rows.Scan(&item.ProductID, &item.Quantity); err != nil {
-			rows.Close()
-			return err
+		var l models.Location
+		if err := scanLocation(rows, &l); err != nil {
+			return nil, err
 		}
-		items = append(items, item)
+		locations = append(locations, l)
 	}
-	rows.Close()
+	return locations, rows.Err()
+}
 
-	if len(items) == 0 {
-		return fmt.Errorf("reservation not found or already processed")
+// availableStock returns the unreserved stock of a record at each location holding it.
+func availableStock(tx *sql.Tx, key skuKey) (map[string]int, error) {
+	rows, err := tx.Query(`
+		SELECT location_id, stock_quantity - reserved_quantity FROM stock_levels
+		WHERE product_id = $1 AND variant_id = $2
+	`, key.ProductID, key.VariantID)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	for _, item := range items {
-		_, err = tx.Exec(`
-			UPDATE inventory
-			SET reserved_quantity = reserved_quantity - $1, last_updated = NOW()
-			WHERE product_id = $2
-		`, item.Quantity, item.ProductID)
-		if err != nil {
-			return err
+	available := map[string]int{}
+	for rows.Next() {
+		var location string
+		var quantity int
+		if err := rows.Scan(&location, &quantity); err != nil {
+			return nil, err
 		}
+		available[location] = quantity
 	}
+	return available, rows.Err()
+}
 
-	_, err = tx.Exec(`UPDATE reservations SET status = 'released' WHERE reservation_id = $1`, reservationID)
+// Release gives the stock held by a pending reservation back to the locations it was
+// allocated from.
+func (s *PostgresStore) Release(reservationID string, key *models.IdempotencyKey, actor string) (*models.StoredResponse, error) {
+	tx, err := s.db.Begin()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer tx.Rollback()
 
-	return tx.Commit()
-}
+	if replay, err := claimIdempotencyKey(tx, key); err != nil || replay != nil {
+		return replay, err
+	}
 
-func (s *PostgresStore) Confirm(reservationID string) error {
-	tx, err := s.db.Begin()
+	rows, err := pendingReservation(tx, reservationID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if len(rows) == 0 {
+		resp := models.OperationResult{Success: false, Message: ErrReservationNotFound.Error()}
+		if err := refuseIdempotent(tx, key, "", http.StatusBadRequest, resp); err != nil {
+			return nil, err
+		}
+		return nil, ErrReservationNotFound
+	}
+	release := models.Movement{Type: models.MovementRelease, Actor: actor}
+	if err := settleReservations(tx, rows, release); err != nil {
+		return nil, err
 	}
-	defer tx.Rollback()
 
-	rows, err := tx.Query(`
-		SELECT product_id, quantity FROM reservations
-		WHERE reservation_id = $1 AND status = 'pending'
-	`, reservationID)
+	_, err = tx.Exec(`UPDATE reservations SET status = 'released' WHERE reservation_id = $1 AND status = 'pending'`, reservationID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := saveIdempotentResponse(tx, key, reservationID, http.StatusOK, models.OperationResult{Success: true}); err != nil {
+		return nil, err
 	}
 
-	var items []models.ReserveItem
-	for rows.Next() {
-		var item models.ReserveItem
-		if err := rows.Scan(&item.ProductID, &item.Quantity); err != nil {
-			rows.Close()
-			return err
-		}
-		items = append(items, item)
+	return nil, tx.Commit()
+}
+
+// Confirm takes the stock held by a pending reservation out of the locations it was
+// allocated from, recording the order it was taken for.
+func (s *PostgresStore) Confirm(req models.ConfirmRequest, key *models.IdempotencyKey, actor string) (*models.StoredResponse, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
 	}
-	rows.Close()
+	defer tx.Rollback()
 
-	if len(items) == 0 {
-		return fmt.Errorf("reservation not found or already processed")
+	if replay, err := claimIdempotencyKey(tx, key); err != nil || replay != nil {
+		return replay, err
 	}
 
-	for _, item := range items {
-		_, err = tx.Exec(`
-			UPDATE inventory
-			SET stock_quantity = stock_quantity - $1,
-			    reserved_quantity = reserved_quantity - $1,
-			    last_updated = NOW()
-			WHERE product_id = $2
-		`, item.Quantity, item.ProductID)
-		if err != nil {
-			return err
+	rows, err := pendingReservation(tx, req.ReservationID)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		resp := models.OperationResult{Success: false, Message: ErrReservationNotFound.Error()}
+		if err := refuseIdempotent(tx, key, "", http.StatusBadRequest, resp); err != nil {
+			return nil, err
 		}
+		return nil, ErrReservationNotFound
+	}
+	confirmation := models.Movement{Type: models.MovementConfirmation, Actor: actor, Reference: req.OrderID}
+	if err := settleReservations(tx, rows, confirmation); err != nil {
+		return nil, err
 	}
 
-	_, err = tx.Exec(`UPDATE reservations SET status = 'confirmed' WHERE reservation_id = $1`, reservationID)
+	_, err = tx.Exec(`UPDATE reservations SET status = 'confirmed' WHERE reservation_id = $1 AND status = 'pending'`, req.ReservationID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := saveIdempotentResponse(tx, key, req.ReservationID, http.StatusOK, models.OperationResult{Success: true}); err != nil {
+		return nil, err
 	}
 
-	return tx.Commit()
+	return nil, tx.Commit()
 }
@@ -0,0 +1,122 @@
+package store
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// migrateCatalogueSync adds the columns that track catalogue product events. A retired
+// row belongs to a deleted or archived product or a removed variant: it keeps its
+// history but is hidden from reads and cannot be reserved.
+func (s *PostgresStore) migrateCatalogueSync() error {
+	_, err := s.db.Exec(`
+	ALTER TABLE inventory ADD COLUMN IF NOT EXISTS retired_at TIMESTAMP;
+	ALTER TABLE inventory ADD COLUMN IF NOT EXISTS catalogue_synced_at TIMESTAMPTZ;
+	`)
+	return err
+}
+
+// SyncProduct makes the product's active stock records match variantIDs as of the
+// catalogue event at occurredAt: missing records are created with no stock, retired
+// ones are restored, and records of variants no longer listed are retired. Created
+// and restored records take the stock state of their stock, so their next change
+// alerts. Events older than the last one applied to the product are ignored.
+func (s *PostgresStore) SyncProduct(productID string, variantIDs []string, occurredAt time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if stale, err := staleCatalogueEvent(tx, productID, occurredAt); err != nil || stale {
+		return err
+	}
+
+	for _, variantID := range variantIDs {
+		res, err := tx.Exec(`
+			INSERT INTO inventory (product_id, variant_id, stock_quantity, reserved_quantity)
+			VALUES ($1, $2, 0, 0)
+			ON CONFLICT (product_id, variant_id) DO UPDATE SET retired_at = NULL, last_updated = NOW()
+			WHERE inventory.retired_at IS NOT NULL
+		`, productID, variantID)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			if err := refreshTotals(tx, skuKey{productID, variantID}); err != nil {
+				return err
+			}
+		}
+	}
+
+	res, err := tx.Exec(`
+		UPDATE inventory SET retired_at = NOW(), stock_state = NULL, last_updated = NOW()
+		WHERE product_id = $1 AND retired_at IS NULL AND NOT (variant_id = ANY($2))
+	`, productID, pq.Array(variantIDs))
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		log.Printf("Retired %d inventory records of product %s no longer in the catalogue", n, productID)
+	}
+
+	if err := markCatalogueSynced(tx, productID, occurredAt); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RetireProduct retires every stock record of a product deleted or archived in the
+// catalogue by the event at occurredAt.
+func (s *PostgresStore) RetireProduct(productID string, occurredAt time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if stale, err := staleCatalogueEvent(tx, productID, occurredAt); err != nil || stale {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE inventory SET retired_at = NOW(), stock_state = NULL, last_updated = NOW()
+		WHERE product_id = $1 AND retired_at IS NULL
+	`, productID)
+	if err != nil {
+		return err
+	}
+
+	if err := markCatalogueSynced(tx, productID, occurredAt); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// staleCatalogueEvent locks the product's rows and reports whether an event newer than
+// occurredAt was already applied. Events for one product arrive on different topics,
+// so they are not guaranteed to be consumed in order.
+func staleCatalogueEvent(tx *sql.Tx, productID string, occurredAt time.Time) (bool, error) {
+	var synced sql.NullTime
+	err := tx.QueryRow(`
+		SELECT MAX(catalogue_synced_at) FROM (
+			SELECT catalogue_synced_at FROM inventory WHERE product_id = $1 FOR UPDATE
+		) t
+	`, productID).Scan(&synced)
+	if err != nil {
+		return false, err
+	}
+	if synced.Valid && synced.Time.After(occurredAt) {
+		log.Printf("Skipping catalogue event for product %s from %s: already synced to %s", productID, occurredAt, synced.Time)
+		return true, nil
+	}
+	return false, nil
+}
+
+func markCatalogueSynced(tx *sql.Tx, productID string, occurredAt time.Time) error {
+	_, err := tx.Exec(`UPDATE inventory SET catalogue_synced_at = $2 WHERE product_id = $1`, productID, occurredAt)
+	return err
+}
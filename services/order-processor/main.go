@@ -14,15 +14,16 @@ import (
 	"time"
 
 	"github.com/IBM/sarama"
+	"github.com/metalbear-co/metalmart/pkg/money"
 )
 
 type OrderCreatedEvent struct {
-	OrderID       string    `json:"order_id"`
-	OrderNumber   string    `json:"order_number"`
-	CustomerEmail string    `json:"customer_email"`
-	TotalAmount   float64   `json:"total_amount"`
-	Status        string    `json:"status"`
-	CreatedAt     time.Time `json:"created_at"`
+	OrderID       string      `json:"order_id"`
+	OrderNumber   string      `json:"order_number"`
+	CustomerEmail string      `json:"customer_email"`
+	TotalAmount   money.Money `json:"total_amount"`
+	Status        string      `json:"status"`
+	CreatedAt     time.Time   `json:"created_at"`
 }
 
 type OrderProcessor struct {
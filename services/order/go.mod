@@ -7,6 +7,7 @@ require (
 	github.com/google/uuid v1.4.0
 	github.com/gorilla/mux v1.8.1
 	github.com/lib/pq v1.10.9
+	github.com/metalbear-co/metalmart/pkg/money v0.0.0
 )
 
 require (
@@ -29,3 +30,5 @@ require (
 	golang.org/x/crypto v0.43.0 // indirect
 	golang.org/x/net v0.46.0 // indirect
 )
+
+replace github.com/metalbear-co/metalmart/pkg/money => ../../pkg/money
@@ -5,8 +5,10 @@ import (
 	"log"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/metalbear-co/metalmart/services/order/kafka"
 	"github.com/metalbear-co/metalmart/services/order/models"
@@ -44,6 +46,10 @@ func (h *Handler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if err := req.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	order, err := h.store.CreateOrder(req)
 	if err != nil {
@@ -64,6 +70,13 @@ func (h *Handler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 			Status:        order.Status,
 			CreatedAt:     order.CreatedAt,
 		}
+		for _, item := range order.Items {
+			event.Items = append(event.Items, models.OrderEventItem{
+				ProductID: item.ProductID,
+				VariantID: item.VariantID,
+				Quantity:  item.Quantity,
+			})
+		}
 		if err := h.producer.PublishOrderCreated(event); err != nil {
 			// Log but don't fail the request
 			log.Printf("Warning: Failed to publish order created event: %v", err)
@@ -108,13 +121,14 @@ func (h *Handler) GetOrderByToken(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) ListOrders(w http.ResponseWriter, r *http.Request) {
-	email := r.URL.Query().Get("email")
+	query := r.URL.Query()
+	email := query.Get("email")
 
 	var orders []models.Order
 	var err error
 
 	if email != "" {
-		orders, err = h.store.ListOrdersByEmail(email)
+		orders, err = h.store.ListOrdersByEmail(email, query.Get("status"), query.Get("product_id"))
 	} else {
 		orders, err = h.store.ListOrders()
 	}
@@ -131,6 +145,41 @@ func (h *Handler) ListOrders(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(orders)
 }
 
+// ListOrderProducts pages through the products of every order, for services that
+// build statistics from the order history. after is the next_cursor of the previous
+// page; limit defaults to 500 and is capped at 1000.
+func (h *Handler) ListOrderProducts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	after := query.Get("after")
+	if after != "" {
+		if _, err := uuid.Parse(after); err != nil {
+			http.Error(w, "after must be an order id", http.StatusBadRequest)
+			return
+		}
+	}
+	limit := 500
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = n
+		if limit > 1000 {
+			limit = 1000
+		}
+	}
+
+	page, err := h.store.ListOrderProducts(after, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
 func (h *Handler) GetOrderStatus(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
@@ -58,6 +58,7 @@ func main() {
 	api := r.PathPrefix("/api").Subrouter()
 	api.HandleFunc("/orders", h.CreateOrder).Methods("POST")
 	api.HandleFunc("/orders", h.ListOrders).Methods("GET")
+	api.HandleFunc("/orders/products", h.ListOrderProducts).Methods("GET")
 	api.HandleFunc("/orders/{id}", h.GetOrder).Methods("GET")
 	api.HandleFunc("/orders/{id}/status", h.GetOrderStatus).Methods("GET")
 	api.HandleFunc("/orders/{id}/status", h.UpdateOrderStatus).Methods("PUT")
@@ -1,22 +1,28 @@
 package models
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/metalbear-co/metalmart/pkg/money"
+)
 
 type Order struct {
-	ID              string         `json:"id"`
-	OrderNumber     string         `json:"order_number"`
-	CustomerEmail   string         `json:"customer_email"`
-	CustomerName    string         `json:"customer_name"`
+	ID              string          `json:"id"`
+	OrderNumber     string          `json:"order_number"`
+	CustomerEmail   string          `json:"customer_email"`
+	CustomerName    string          `json:"customer_name"`
 	ShippingAddress ShippingAddress `json:"shipping_address"`
-	TotalAmount     float64        `json:"total_amount"`
-	Status          string         `json:"status"`
-	TrackingToken   string         `json:"tracking_token"`
-	ProcessedBy     string         `json:"processed_by,omitempty"`
-	SourceTopic     string         `json:"source_topic,omitempty"`
-	Source          string         `json:"source,omitempty"` // "mirrord" or "cluster"
-	Items           []OrderItem    `json:"items,omitempty"`
-	CreatedAt       time.Time      `json:"created_at"`
-	UpdatedAt       time.Time      `json:"updated_at"`
+	TotalAmount     money.Money     `json:"total_amount"`
+	Status          string          `json:"status"`
+	TrackingToken   string          `json:"tracking_token"`
+	ProcessedBy     string          `json:"processed_by,omitempty"`
+	SourceTopic     string          `json:"source_topic,omitempty"`
+	Source          string          `json:"source,omitempty"` // "mirrord" or "cluster"
+	Items           []OrderItem     `json:"items,omitempty"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
 }
 
 type ShippingAddress struct {
@@ -28,28 +34,79 @@ type ShippingAddress struct {
 }
 
 type OrderItem struct {
-	ID          string    `json:"id"`
-	OrderID     string    `json:"order_id"`
-	ProductID   string    `json:"product_id"`
-	ProductName string    `json:"product_name"`
-	Quantity    int       `json:"quantity"`
-	PriceAtTime float64   `json:"price_at_time"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID          string      `json:"id"`
+	OrderID     string      `json:"order_id"`
+	ProductID   string      `json:"product_id"`
+	VariantID   string      `json:"variant_id,omitempty"`
+	ProductName string      `json:"product_name"`
+	Quantity    int         `json:"quantity"`
+	PriceAtTime money.Money `json:"price_at_time"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
+
+// OrderProducts lists the distinct products bought in one order.
+type OrderProducts struct {
+	OrderID    string   `json:"order_id"`
+	ProductIDs []string `json:"product_ids"`
+}
+
+// OrderProductsPage is a page of GET /api/orders/products, ordered by order id.
+// NextCursor is passed as after to fetch the next page and is empty on the last one.
+type OrderProductsPage struct {
+	Orders     []OrderProducts `json:"orders"`
+	NextCursor string          `json:"next_cursor,omitempty"`
 }
 
 type CreateOrderRequest struct {
-	CustomerEmail   string          `json:"customer_email"`
-	CustomerName    string          `json:"customer_name"`
-	ShippingAddress ShippingAddress `json:"shipping_address"`
+	CustomerEmail   string           `json:"customer_email"`
+	CustomerName    string           `json:"customer_name"`
+	ShippingAddress ShippingAddress  `json:"shipping_address"`
 	Items           []OrderItemInput `json:"items"`
-	ReservationID   string          `json:"reservation_id"`
+	ReservationID   string           `json:"reservation_id"`
+	Currency        string           `json:"currency,omitempty"`
+}
+
+// Validate normalizes item currencies and checks that the order can be totalled:
+// quantities are positive, prices are not negative and every item has the same currency.
+func (r *CreateOrderRequest) Validate() error {
+	if len(r.Items) == 0 {
+		return errors.New("order must contain at least one item")
+	}
+	if r.Currency == "" {
+		r.Currency = r.Items[0].Price.Currency
+	}
+	r.Currency = money.NormalizeCurrency(r.Currency)
+	for i := range r.Items {
+		item := &r.Items[i]
+		item.Price.Currency = money.NormalizeCurrency(item.Price.Currency)
+		if item.Quantity <= 0 {
+			return fmt.Errorf("quantity of product %s must be positive", item.ProductID)
+		}
+		if item.Price.Amount < 0 {
+			return fmt.Errorf("price of product %s must not be negative", item.ProductID)
+		}
+		if item.Price.Currency != r.Currency {
+			return fmt.Errorf("all items must be priced in the order currency %s (product %s is in %s)", r.Currency, item.ProductID, item.Price.Currency)
+		}
+	}
+	return nil
+}
+
+// Total sums the item prices. Call Validate first.
+func (r CreateOrderRequest) Total() money.Money {
+	total := money.Money{Currency: r.Currency}
+	for _, item := range r.Items {
+		total.Amount += item.Price.Mul(item.Quantity).Amount
+	}
+	return total
 }
 
 type OrderItemInput struct {
-	ProductID   string  `json:"product_id"`
-	ProductName string  `json:"product_name"`
-	Quantity    int     `json:"quantity"`
-	Price       float64 `json:"price"`
+	ProductID   string      `json:"product_id"`
+	VariantID   string      `json:"variant_id,omitempty"`
+	ProductName string      `json:"product_name"`
+	Quantity    int         `json:"quantity"`
+	Price       money.Money `json:"price"`
 }
 
 type UpdateStatusRequest struct {
@@ -57,10 +114,18 @@ type UpdateStatusRequest struct {
 }
 
 type OrderCreatedEvent struct {
-	OrderID       string    `json:"order_id"`
-	OrderNumber   string    `json:"order_number"`
-	CustomerEmail string    `json:"customer_email"`
-	TotalAmount   float64   `json:"total_amount"`
-	Status        string    `json:"status"`
-	CreatedAt     time.Time `json:"created_at"`
+	OrderID       string           `json:"order_id"`
+	OrderNumber   string           `json:"order_number"`
+	CustomerEmail string           `json:"customer_email"`
+	TotalAmount   money.Money      `json:"total_amount"`
+	Status        string           `json:"status"`
+	Items         []OrderEventItem `json:"items"`
+	CreatedAt     time.Time        `json:"created_at"`
+}
+
+// OrderEventItem is a line of an order as published in OrderCreatedEvent.
+type OrderEventItem struct {
+	ProductID string `json:"product_id"`
+	VariantID string `json:"variant_id,omitempty"`
+	Quantity  int    `json:"quantity"`
 }
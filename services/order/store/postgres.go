@@ -7,7 +7,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/metalbear-co/metalmart/services/order/models"
 )
 
@@ -40,13 +40,15 @@ func (s *PostgresStore) Migrate() error {
 		customer_email VARCHAR(255),
 		customer_name VARCHAR(255),
 		shipping_address JSONB,
-		total_amount DECIMAL(10,2),
+		total_cents BIGINT,
+		currency CHAR(3) NOT NULL DEFAULT 'USD',
 		status VARCHAR(50) DEFAULT 'pending',
 		tracking_token UUID DEFAULT gen_random_uuid(),
 		created_at TIMESTAMP DEFAULT NOW(),
 		updated_at TIMESTAMP DEFAULT NOW()
 	);
 	CREATE INDEX IF NOT EXISTS idx_orders_email ON orders(customer_email);
+	CREATE INDEX IF NOT EXISTS idx_orders_email_lower ON orders(lower(customer_email));
 	CREATE INDEX IF NOT EXISTS idx_orders_token ON orders(tracking_token);
 	CREATE INDEX IF NOT EXISTS idx_orders_status ON orders(status);
 
@@ -56,7 +58,8 @@ func (s *PostgresStore) Migrate() error {
 		product_id VARCHAR(50),
 		product_name VARCHAR(255),
 		quantity INTEGER,
-		price_at_time DECIMAL(10,2),
+		price_at_time_cents BIGINT,
+		currency CHAR(3) NOT NULL DEFAULT 'USD',
 		created_at TIMESTAMP DEFAULT NOW()
 	);
 	CREATE INDEX IF NOT EXISTS idx_order_items_order_id ON order_items(order_id);
@@ -68,7 +71,32 @@ func (s *PostgresStore) Migrate() error {
 	if _, err := s.db.Exec(`ALTER TABLE orders ADD COLUMN IF NOT EXISTS processor_source VARCHAR(100)`); err != nil {
 		return err
 	}
-	_, err := s.db.Exec(`ALTER TABLE orders ADD COLUMN IF NOT EXISTS source_topic VARCHAR(255)`)
+	if _, err := s.db.Exec(`ALTER TABLE orders ADD COLUMN IF NOT EXISTS source_topic VARCHAR(255)`); err != nil {
+		return err
+	}
+	// Convert the pre-money DECIMAL amount columns to integer cents
+	query = `
+	DO $$
+	BEGIN
+		IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'orders' AND column_name = 'total_amount') THEN
+			ALTER TABLE orders ADD COLUMN IF NOT EXISTS total_cents BIGINT;
+			ALTER TABLE orders ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+			UPDATE orders SET total_cents = ROUND(total_amount * 100) WHERE total_cents IS NULL;
+			ALTER TABLE orders DROP COLUMN total_amount;
+		END IF;
+		IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'order_items' AND column_name = 'price_at_time') THEN
+			ALTER TABLE order_items ADD COLUMN IF NOT EXISTS price_at_time_cents BIGINT;
+			ALTER TABLE order_items ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+			UPDATE order_items SET price_at_time_cents = ROUND(price_at_time * 100) WHERE price_at_time_cents IS NULL;
+			ALTER TABLE order_items DROP COLUMN price_at_time;
+		END IF;
+	END $$;
+	`
+	if _, err := s.db.Exec(query); err != nil {
+		return err
+	}
+	// Items may reference a product variant (size/colour SKU); '' means the product itself
+	_, err := s.db.Exec(`ALTER TABLE order_items ADD COLUMN IF NOT EXISTS variant_id VARCHAR(50) NOT NULL DEFAULT ''`)
 	return err
 }
 
@@ -83,10 +111,7 @@ func (s *PostgresStore) CreateOrder(req models.CreateOrderRequest) (*models.Orde
 	}
 	defer tx.Rollback()
 
-	var totalAmount float64
-	for _, item := range req.Items {
-		totalAmount += item.Price * float64(item.Quantity)
-	}
+	totalAmount := req.Total()
 
 	addressJSON, err := json.Marshal(req.ShippingAddress)
 	if err != nil {
@@ -97,12 +122,12 @@ func (s *PostgresStore) CreateOrder(req models.CreateOrderRequest) (*models.Orde
 	var order models.Order
 
 	err = tx.QueryRow(`
-		INSERT INTO orders (order_number, customer_email, customer_name, shipping_address, total_amount, status)
-		VALUES ($1, $2, $3, $4, $5, 'pending')
-		RETURNING id, order_number, customer_email, customer_name, shipping_address, total_amount, status, tracking_token, created_at, updated_at
-	`, orderNumber, req.CustomerEmail, req.CustomerName, addressJSON, totalAmount).Scan(
+		INSERT INTO orders (order_number, customer_email, customer_name, shipping_address, total_cents, currency, status)
+		VALUES ($1, $2, $3, $4, $5, $6, 'pending')
+		RETURNING id, order_number, customer_email, customer_name, shipping_address, total_cents, currency, status, tracking_token, created_at, updated_at
+	`, orderNumber, req.CustomerEmail, req.CustomerName, addressJSON, totalAmount.Amount, totalAmount.Currency).Scan(
 		&order.ID, &order.OrderNumber, &order.CustomerEmail, &order.CustomerName,
-		&addressJSON, &order.TotalAmount, &order.Status, &order.TrackingToken,
+		&addressJSON, &order.TotalAmount.Amount, &order.TotalAmount.Currency, &order.Status, &order.TrackingToken,
 		&order.CreatedAt, &order.UpdatedAt,
 	)
 	if err != nil {
@@ -114,12 +139,12 @@ func (s *PostgresStore) CreateOrder(req models.CreateOrderRequest) (*models.Orde
 	for _, item := range req.Items {
 		var orderItem models.OrderItem
 		err = tx.QueryRow(`
-			INSERT INTO order_items (order_id, product_id, product_name, quantity, price_at_time)
-			VALUES ($1, $2, $3, $4, $5)
-			RETURNING id, order_id, product_id, product_name, quantity, price_at_time, created_at
-		`, order.ID, item.ProductID, item.ProductName, item.Quantity, item.Price).Scan(
-			&orderItem.ID, &orderItem.OrderID, &orderItem.ProductID, &orderItem.ProductName,
-			&orderItem.Quantity, &orderItem.PriceAtTime, &orderItem.CreatedAt,
+			INSERT INTO order_items (order_id, product_id, variant_id, product_name, quantity, price_at_time_cents, currency)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id, order_id, product_id, variant_id, product_name, quantity, price_at_time_cents, currency, created_at
+		`, order.ID, item.ProductID, item.VariantID, item.ProductName, item.Quantity, item.Price.Amount, item.Price.Currency).Scan(
+			&orderItem.ID, &orderItem.OrderID, &orderItem.ProductID, &orderItem.VariantID, &orderItem.ProductName,
+			&orderItem.Quantity, &orderItem.PriceAtTime.Amount, &orderItem.PriceAtTime.Currency, &orderItem.CreatedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -135,7 +160,7 @@ func (s *PostgresStore) CreateOrder(req models.CreateOrderRequest) (*models.Orde
 }
 
 func (s *PostgresStore) GetOrder(id string) (*models.Order, error) {
-	order, err := s.getOrderByQuery(`SELECT id, order_number, customer_email, customer_name, shipping_address, total_amount, status, tracking_token, created_at, updated_at FROM orders WHERE id = $1`, id)
+	order, err := s.getOrderByQuery(`SELECT id, order_number, customer_email, customer_name, shipping_address, total_cents, currency, status, tracking_token, created_at, updated_at FROM orders WHERE id = $1`, id)
 	if err != nil {
 		return nil, err
 	}
@@ -144,7 +169,7 @@ func (s *PostgresStore) GetOrder(id string) (*models.Order, error) {
 }
 
 func (s *PostgresStore) GetOrderByToken(token string) (*models.Order, error) {
-	order, err := s.getOrderByQuery(`SELECT id, order_number, customer_email, customer_name, shipping_address, total_amount, status, tracking_token, created_at, updated_at FROM orders WHERE tracking_token = $1`, token)
+	order, err := s.getOrderByQuery(`SELECT id, order_number, customer_email, customer_name, shipping_address, total_cents, currency, status, tracking_token, created_at, updated_at FROM orders WHERE tracking_token = $1`, token)
 	if err != nil {
 		return nil, err
 	}
@@ -155,15 +180,17 @@ func (s *PostgresStore) GetOrderByToken(token string) (*models.Order, error) {
 func (s *PostgresStore) getOrderByQuery(query string, arg interface{}) (*models.Order, error) {
 	var order models.Order
 	var addressJSON []byte
+	var totalCents sql.NullInt64 // NULL on orders whose legacy total_amount was NULL
 
 	err := s.db.QueryRow(query, arg).Scan(
 		&order.ID, &order.OrderNumber, &order.CustomerEmail, &order.CustomerName,
-		&addressJSON, &order.TotalAmount, &order.Status, &order.TrackingToken,
+		&addressJSON, &totalCents, &order.TotalAmount.Currency, &order.Status, &order.TrackingToken,
 		&order.CreatedAt, &order.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
 	}
+	order.TotalAmount.Amount = totalCents.Int64
 
 	json.Unmarshal(addressJSON, &order.ShippingAddress)
 
@@ -186,7 +213,7 @@ func (s *PostgresStore) fillProcessorSource(order *models.Order, byID bool, val
 
 func (s *PostgresStore) ListOrders() ([]models.Order, error) {
 	rows, err := s.db.Query(`
-		SELECT id, order_number, customer_email, customer_name, shipping_address, total_amount, status, tracking_token, created_at, updated_at
+		SELECT id, order_number, customer_email, customer_name, shipping_address, total_cents, currency, status, tracking_token, created_at, updated_at
 		FROM orders ORDER BY created_at DESC LIMIT 100
 	`)
 	if err != nil {
@@ -197,11 +224,18 @@ func (s *PostgresStore) ListOrders() ([]models.Order, error) {
 	return s.scanOrders(rows)
 }
 
-func (s *PostgresStore) ListOrdersByEmail(email string) ([]models.Order, error) {
+// ListOrdersByEmail returns a customer's orders, optionally only those in status and
+// those containing productID; empty filters match everything. Emails match
+// case-insensitively.
+func (s *PostgresStore) ListOrdersByEmail(email, status, productID string) ([]models.Order, error) {
 	rows, err := s.db.Query(`
-		SELECT id, order_number, customer_email, customer_name, shipping_address, total_amount, status, tracking_token, created_at, updated_at
-		FROM orders WHERE customer_email = $1 ORDER BY created_at DESC
-	`, email)
+		SELECT id, order_number, customer_email, customer_name, shipping_address, total_cents, currency, status, tracking_token, created_at, updated_at
+		FROM orders o
+		WHERE lower(customer_email) = lower($1)
+			AND ($2::text = '' OR status = $2)
+			AND ($3::text = '' OR EXISTS (SELECT 1 FROM order_items i WHERE i.order_id = o.id AND i.product_id = $3))
+		ORDER BY created_at DESC
+	`, email, status, productID)
 	if err != nil {
 		return nil, err
 	}
@@ -210,19 +244,57 @@ func (s *PostgresStore) ListOrdersByEmail(email string) ([]models.Order, error)
 	return s.scanOrders(rows)
 }
 
+// ListOrderProducts returns up to limit orders with their distinct products, ordered
+// by id and starting after the order id after ("" for the first page). Orders without
+// items are skipped.
+func (s *PostgresStore) ListOrderProducts(after string, limit int) (*models.OrderProductsPage, error) {
+	rows, err := s.db.Query(`
+		SELECT o.id, array_agg(DISTINCT i.product_id ORDER BY i.product_id)
+		FROM orders o
+		JOIN order_items i ON i.order_id = o.id AND i.product_id IS NOT NULL AND i.product_id <> ''
+		WHERE $1::text = '' OR o.id > $1::uuid
+		GROUP BY o.id
+		ORDER BY o.id
+		LIMIT $2
+	`, after, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &models.OrderProductsPage{Orders: []models.OrderProducts{}}
+	for rows.Next() {
+		var o models.OrderProducts
+		if err := rows.Scan(&o.OrderID, pq.Array(&o.ProductIDs)); err != nil {
+			return nil, err
+		}
+		page.Orders = append(page.Orders, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(page.Orders) > limit {
+		page.Orders = page.Orders[:limit]
+		page.NextCursor = page.Orders[limit-1].OrderID
+	}
+	return page, nil
+}
+
 func (s *PostgresStore) scanOrders(rows *sql.Rows) ([]models.Order, error) {
 	var orders []models.Order
 	for rows.Next() {
 		var order models.Order
 		var addressJSON []byte
+		var totalCents sql.NullInt64
 		err := rows.Scan(
 			&order.ID, &order.OrderNumber, &order.CustomerEmail, &order.CustomerName,
-			&addressJSON, &order.TotalAmount, &order.Status, &order.TrackingToken,
+			&addressJSON, &totalCents, &order.TotalAmount.Currency, &order.Status, &order.TrackingToken,
 			&order.CreatedAt, &order.UpdatedAt,
 		)
 		if err != nil {
 			return nil, err
 		}
+		order.TotalAmount.Amount = totalCents.Int64
 		json.Unmarshal(addressJSON, &order.ShippingAddress)
 		_ = s.db.QueryRow(`SELECT COALESCE(processor_source, ''), COALESCE(source_topic, '') FROM orders WHERE id = $1`, order.ID).Scan(&order.ProcessedBy, &order.SourceTopic)
 		orders = append(orders, order)
@@ -235,7 +307,7 @@ func (s *PostgresStore) scanOrders(rows *sql.Rows) ([]models.Order, error) {
 
 func (s *PostgresStore) getOrderItems(orderID string) ([]models.OrderItem, error) {
 	rows, err := s.db.Query(`
-		SELECT id, order_id, product_id, product_name, quantity, price_at_time, created_at
+		SELECT id, order_id, product_id, variant_id, product_name, quantity, price_at_time_cents, currency, created_at
 		FROM order_items WHERE order_id = $1
 	`, orderID)
 	if err != nil {
@@ -246,10 +318,12 @@ func (s *PostgresStore) getOrderItems(orderID string) ([]models.OrderItem, error
 	var items []models.OrderItem
 	for rows.Next() {
 		var item models.OrderItem
-		err := rows.Scan(&item.ID, &item.OrderID, &item.ProductID, &item.ProductName, &item.Quantity, &item.PriceAtTime, &item.CreatedAt)
+		var priceCents sql.NullInt64
+		err := rows.Scan(&item.ID, &item.OrderID, &item.ProductID, &item.VariantID, &item.ProductName, &item.Quantity, &priceCents, &item.PriceAtTime.Currency, &item.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
+		item.PriceAtTime.Amount = priceCents.Int64
 		items = append(items, item)
 	}
 	if items == nil {